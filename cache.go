@@ -0,0 +1,199 @@
+package restache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// cacheVersion is part of every cache key. Change it whenever the output for
+// the same template and options changes, so persisted entries are dropped.
+const cacheVersion = "restache/9"
+
+// A cacheEntry is the compiled output of a template and its warnings, along
+// with every resolution made while compiling it.
+type cacheEntry struct {
+	Key         string         `json:"key"`
+	Contents    string         `json:"contents"`
+	Styles      string         `json:"styles,omitempty"`
+	Resolutions []resolution   `json:"resolutions,omitempty"`
+	Warnings    []*SyntaxError `json:"warnings,omitempty"`
+}
+
+// A resolution records the path an import resolved to, or "" if it failed.
+// Resolutions of a tag by the Resolver of the plugin record its ImportSpec
+// as "path#export".
+type resolution struct {
+	Path     string `json:"path,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Resolved string `json:"resolved,omitempty"`
+}
+
+// compileCache holds the latest entry of every template, and optionally
+// persists entries to a directory.
+type compileCache struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry // by template path
+}
+
+func newCompileCache(dir string) *compileCache {
+	return &compileCache{dir: dir, entries: make(map[string]*cacheEntry)}
+}
+
+// get returns the entry of file with key, or nil.
+func (c *compileCache) get(file, key string) *cacheEntry {
+	c.mu.Lock()
+	e := c.entries[file]
+	c.mu.Unlock()
+	if e != nil && e.Key == key {
+		return e
+	}
+	if c.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	e = new(cacheEntry)
+	if json.Unmarshal(data, e) != nil || e.Key != key {
+		return nil
+	}
+	c.mu.Lock()
+	c.entries[file] = e
+	c.mu.Unlock()
+	return e
+}
+
+// styles returns the styles of the latest entry of file.
+func (c *compileCache) styles(file string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[file]
+	if e == nil {
+		return "", false
+	}
+	return e.Styles, true
+}
+
+// put stores e as the entry of file, writing it to the cache directory if
+// there is one.
+func (c *compileCache) put(file string, e *cacheEntry) error {
+	c.mu.Lock()
+	c.entries[file] = e
+	c.mu.Unlock()
+	if c.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so concurrent builds sharing the
+	// directory never read a partial entry.
+	f, err := os.CreateTemp(c.dir, e.Key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(c.dir, e.Key+".json"))
+}
+
+// cacheKey identifies the output of compiling the template at path, with
+// contents data, under the plugin's options.
+func (p *plugin) cacheKey(path string, data []byte) string {
+	cfg := *p.cfg
+	// the resolver is checked against the recorded resolutions instead
+	cfg.extName, cfg.cacheDir, cfg.jsxRuntime, cfg.resolver = "", "", nil, nil
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%#v\x00%t\x00", cacheVersion, path, cfg, p.cfg.resolver != nil)
+	if p.cfg.jsxRuntime != nil {
+		fmt.Fprintf(h, "%#v\x00", *p.cfg.jsxRuntime)
+	}
+	h.Write(data)
+	// a CSS module that cannot be read fails the compile instead
+	if module, err := p.cssModule(path); err == nil && module != nil {
+		h.Write([]byte{0})
+		h.Write(module)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recording returns a copy of p that appends every resolution it makes to
+// rec.
+func (p *plugin) recording(rec *[]resolution) *plugin {
+	q := *p
+	q.resolveFunc = func(path string, options api.ResolveOptions) api.ResolveResult {
+		result := p.resolveFunc(path, options)
+		r := resolution{Path: path}
+		if len(result.Errors) == 0 && len(result.Warnings) == 0 {
+			r.Resolved = result.Path
+		}
+		*rec = append(*rec, r)
+		return result
+	}
+	if p.cfg.resolver != nil {
+		cfg := *p.cfg
+		cfg.resolver = recordingResolver{p.cfg.resolver, rec}
+		q.cfg = &cfg
+	}
+	return &q
+}
+
+// recordingResolver appends every resolution of r to rec.
+type recordingResolver struct {
+	r   Resolver
+	rec *[]resolution
+}
+
+func (r recordingResolver) Resolve(tag, importer string) (ImportSpec, error) {
+	spec, err := r.r.Resolve(tag, importer)
+	*r.rec = append(*r.rec, resolution{Tag: tag, Resolved: resolvedSpec(spec, err)})
+	return spec, err
+}
+
+func resolvedSpec(spec ImportSpec, err error) string {
+	if err != nil || spec.Path == "" {
+		return ""
+	}
+	return spec.Path + "#" + spec.Export
+}
+
+// resolvesAs reports whether every import in rec still resolves as it did
+// for the template at importer.
+func (p *plugin) resolvesAs(rec []resolution, importer string) bool {
+	for _, r := range rec {
+		var resolved string
+		if r.Tag != "" {
+			if p.cfg.resolver == nil {
+				return false
+			}
+			resolved = resolvedSpec(p.cfg.resolver.Resolve(r.Tag, importer))
+		} else if path, _, err := p.resolvePath(r.Path, filepath.Dir(importer)); err == nil {
+			resolved = path
+		}
+		if resolved != r.Resolved {
+			return false
+		}
+	}
+	return true
+}
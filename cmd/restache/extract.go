@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetsuo/restache"
+)
+
+func extract(args []string, stdout io.Writer) error {
+	fset := flag.NewFlagSet("extract", flag.ExitOnError)
+	var (
+		format = fset.String("format", "json", "catalog format: json or po")
+		out    = fset.String("o", "", "write the catalog to `file` instead of standard output")
+		ext    = fset.String("ext", ".stache", "template file extension")
+		text   = fset.Bool("text", false, "also extract untagged text")
+	)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: restache extract [flags] [path ...]")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+
+	if *format != "json" && *format != "po" {
+		return fmt.Errorf("unknown catalog format %q", *format)
+	}
+	paths := fset.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	cat := make(restache.Catalog)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, *ext) {
+				return err
+			}
+			messages, err := extractFile(path, *text)
+			if err != nil {
+				return err
+			}
+			return cat.Add(messages...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := createOutput(*out, stdout)
+	if err != nil {
+		return err
+	}
+	if *format == "po" {
+		err = cat.WritePO(w)
+	} else {
+		err = cat.WriteJSON(w)
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func extractFile(path string, text bool) ([]restache.Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	root, err := restache.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	messages, err := restache.ExtractMessages(root, filepath.ToSlash(path), text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return messages, nil
+}
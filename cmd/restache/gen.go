@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetsuo/restache"
+)
+
+func gen(args []string, stdout io.Writer) error {
+	fset := flag.NewFlagSet("gen", flag.ExitOnError)
+	var (
+		pkg = fset.String("pkg", os.Getenv("GOPACKAGE"), "package `name` of the generated file (default $GOPACKAGE)")
+		out = fset.String("o", "", "write the Go source to `file` instead of standard output")
+		ext = fset.String("ext", ".stache", "template file extension")
+	)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: restache gen [flags] [path ...]")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+
+	if !token.IsIdentifier(*pkg) {
+		return fmt.Errorf("invalid package name %q; set -pkg", *pkg)
+	}
+	paths := fset.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var roots []*restache.Node
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, *ext) {
+				return err
+			}
+			n, err := parseFile(path)
+			if err != nil {
+				return err
+			}
+			roots = append(roots, n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var src bytes.Buffer
+	if err := restache.GenerateGo(&src, *pkg, roots); err != nil {
+		return err
+	}
+	w, err := createOutput(*out, stdout)
+	if err != nil {
+		return err
+	}
+	_, err = src.WriteTo(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// parseFile parses the template at path, named after its file.
+func parseFile(path string) (*restache.Node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	root, err := restache.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	root.Data = restache.ComponentName(path)
+	if !token.IsIdentifier(root.Data) {
+		return nil, fmt.Errorf("%s: %q is not a valid component name", path, root.Data)
+	}
+	return root, nil
+}
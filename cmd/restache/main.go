@@ -0,0 +1,61 @@
+// Command restache provides tooling for restache templates.
+//
+// Usage:
+//
+//	restache extract [flags] [path ...]
+//	restache gen [flags] [path ...]
+//
+// The extract command collects the translatable messages of every template
+// found under the given paths into a message catalog.
+//
+// The gen command writes a Go file rendering every template found under the
+// given paths to HTML, for use with go:generate:
+//
+//	//go:generate restache gen -o views_gen.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: restache <command> [flags] [path ...]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  extract  write a message catalog for the templates' translations")
+	fmt.Fprintln(os.Stderr, "  gen      write Go functions rendering the templates to HTML")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "extract":
+		err = extract(os.Args[2:], os.Stdout)
+	case "gen":
+		err = gen(os.Args[2:], os.Stdout)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "restache:", err)
+		os.Exit(1)
+	}
+}
+
+// createOutput returns a writer for path, or w if path is empty or "-".
+func createOutput(path string, w io.Writer) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{w}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
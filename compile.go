@@ -0,0 +1,149 @@
+package restache
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// Result is the outcome of compiling one template.
+type Result struct {
+	// Contents is the compiled module, empty if Err is set. It is JSX,
+	// unless WithCreateElement is used.
+	Contents string
+	// Styles is the stylesheet of the template, built from its scoped style
+	// and CSS module, if it has any. Contents do not import it.
+	Styles string
+	// Err is the first problem found in the template; a *SyntaxError if
+	// the template is malformed.
+	Err error
+	// Warnings report uses of props a component does not declare.
+	Warnings []*SyntaxError
+}
+
+// CompileAll compiles the templates in fsys that match patterns, using the
+// same options and component resolution as the esbuild plugin. Patterns use
+// the syntax of fs.Glob; a pattern matching a directory selects every
+// template below it.
+//
+// Templates are compiled concurrently. Results are keyed by path, and each
+// carries its own error, so a broken template does not stop the others. The
+// returned error is only set if a pattern is malformed or fsys cannot be
+// read.
+//
+// Components and modules are resolved within fsys relative to the template,
+// trying the template extension and then those of esbuild; the compiled
+// imports are relative paths. Specifiers that are not relative paths are left
+// to the consuming bundler.
+func CompileAll(fsys fs.FS, patterns []string, opts ...PluginOption) (map[string]Result, error) {
+	cfg := pluginConfig{}
+	readPluginConfig(&cfg, opts...)
+	if cfg.extName == "" {
+		cfg.extName = ".stache"
+	}
+
+	files, err := globTemplates(fsys, patterns, cfg.extName)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plugin{
+		cfg:         &cfg,
+		resolveFunc: fsResolver(fsys, cfg.extName),
+		readFile:    func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) },
+		readDir:     func(name string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, filepath.ToSlash(name)) },
+	}
+
+	results := make([]Result, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				data, err := fs.ReadFile(fsys, files[i])
+				if err == nil {
+					results[i].Contents, results[i].Styles, results[i].Warnings, err = p.compile(files[i], data)
+				}
+				results[i].Err = err
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	m := make(map[string]Result, len(files))
+	for i, name := range files {
+		m[name] = results[i]
+	}
+	return m, nil
+}
+
+// globTemplates returns the sorted, unique paths of the templates matching
+// patterns.
+func globTemplates(fsys fs.FS, patterns []string, extName string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			err := fs.WalkDir(fsys, name, func(name string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.HasSuffix(name, extName) {
+					files = append(files, name)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// resolveExtensions are tried, in order, after the template extension when
+// an import has none, as esbuild does by default.
+var resolveExtensions = []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
+
+// fsResolver returns a resolve function that finds relative imports in fsys
+// and reports them relative to the importing directory. Other imports are
+// external.
+func fsResolver(fsys fs.FS, extName string) func(string, api.ResolveOptions) api.ResolveResult {
+	exts := append([]string{"", extName}, resolveExtensions...)
+	return func(spec string, options api.ResolveOptions) api.ResolveResult {
+		spec = filepath.ToSlash(spec)
+		if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+			return api.ResolveResult{Path: spec, External: true}
+		}
+		dir := filepath.ToSlash(options.ResolveDir)
+		base := path.Join(dir, spec)
+		for _, candidate := range []string{base, base + "/index"} {
+			for _, ext := range exts {
+				if info, err := fs.Stat(fsys, candidate+ext); err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(dir, candidate+ext)
+					if rel = filepath.ToSlash(rel); !strings.HasPrefix(rel, "../") {
+						rel = "./" + rel
+					}
+					return api.ResolveResult{Path: rel}
+				}
+			}
+		}
+		return api.ResolveResult{Errors: []api.Message{{Text: "Could not resolve \"" + spec + "\""}}}
+	}
+}
@@ -0,0 +1,77 @@
+package restache_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestCompileAll(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/page.stache":        {Data: []byte("<main><ui:card>{title}</ui:card><page-footer /></main>")},
+		"app/page-footer.stache": {Data: []byte("<footer>{year | fmt}</footer>")},
+		"app/broken.stache":      {Data: []byte("<p>{a +}</p>")},
+		"app/missing.stache":     {Data: []byte("<nav-bar></nav-bar>")},
+		"app/list.stache":        {Data: []byte(`<ul>{#items}<page-footer />{/items}</ul><template name="page-footer"><li>{name}</li></template>`)},
+		"app/row.stache":         {Data: []byte(`<tr></tr><template name="row"></template>`)},
+		"app/notes.txt":          {Data: []byte("{not a template")},
+		"ui/Card/index.jsx":      {Data: []byte("export default () => null;")},
+		"shared/format.js":       {Data: []byte("export const fmt = String;")},
+		"shared/empty.stache":    {Data: []byte("")},
+		"shared/nested/x.stache": {Data: []byte("<i></i>")},
+		"unmatched/other.stache": {Data: []byte("<i></i>")},
+	}
+
+	results, err := restache.CompileAll(fsys, []string{"app", "shared/*", "app/page.stache"},
+		restache.WithTagPrefixes(map[string]string{"ui": "../ui"}),
+		restache.WithFilters(map[string]string{"fmt": "../shared/format"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app/page.stache": "import * as React from 'react';\n" +
+			"import Card from '../ui/Card/index.jsx';\n" +
+			"import PageFooter from './page-footer.stache';\n" +
+			"export default function Page($0) {return <main><Card>{$0.title}</Card><PageFooter></PageFooter></main>;}",
+		"app/page-footer.stache": "import * as React from 'react';\n" +
+			"import { fmt } from '../shared/format.js';\n" +
+			"export default function PageFooter($0) {return <footer>{fmt($0.year)}</footer>;}",
+		"app/list.stache": "import * as React from 'react';\n" +
+			"export default function List($0) {return <ul>{($0.items ?? []).map($1 => <PageFooter key={ $1.key }></PageFooter>)}</ul>;}\n" +
+			"export function PageFooter($0) {return <li>{$0.name}</li>;}",
+		"shared/empty.stache":    "import * as React from 'react';\nexport default function Empty($0) {return <></>;}",
+		"shared/nested/x.stache": "import * as React from 'react';\nexport default function X($0) {return <i></i>;}",
+	}
+	if len(results) != len(want)+3 {
+		t.Errorf("got %d results, want %d", len(results), len(want)+3)
+	}
+	for name, contents := range want {
+		r, ok := results[name]
+		if !ok || r.Err != nil {
+			t.Errorf("%s: missing or failed: %v", name, r.Err)
+			continue
+		}
+		if r.Contents != contents {
+			t.Errorf("%s:\nwant:\n%s\ngot:\n%s", name, contents, r.Contents)
+		}
+	}
+
+	var serr *restache.SyntaxError
+	if r := results["app/broken.stache"]; !errors.As(r.Err, &serr) || serr.Pos.Line != 1 {
+		t.Errorf("broken: got %v, want a syntax error", r.Err)
+	}
+	if r := results["app/row.stache"]; !errors.As(r.Err, &serr) || serr.Msg != `component "Row" has the name of the file` {
+		t.Errorf("row: got %v, want a name clash", r.Err)
+	}
+	if r := results["app/missing.stache"]; r.Err == nil || r.Contents != "" {
+		t.Errorf("missing: got %q, %v, want a resolution error", r.Contents, r.Err)
+	}
+
+	if _, err := restache.CompileAll(fsys, []string{"[a"}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
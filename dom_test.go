@@ -0,0 +1,92 @@
+package restache_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestRenderDOM(t *testing.T) {
+	const file = "testdata/render_dom.txt"
+	for _, tc := range buildTestcases(t, file) {
+		t.Run(fmt.Sprintf("%s L%d", file, tc.line), func(t *testing.T) {
+			var sb strings.Builder
+			_, err := restache.RenderWithOptions(&sb, parseNode(t, tc.data), restache.RenderOptions{Target: restache.TargetDOM})
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := sb.String()
+			want := "export default function ($0) {return $mount(($0) => {const $n1 = document.createDocumentFragment();" + tc.expected + "}, $0);}"
+
+			if got != want {
+				t.Errorf("Render mismatch at line %d:\nwant:\n%s\ngot:\n%s\n", tc.line, want, got)
+			}
+		})
+	}
+}
+
+func TestCompileDOM(t *testing.T) {
+	fsys := fstest.MapFS{
+		"todo-list.stache": {Data: []byte("{@props items=[]}<ul>{#items}<todo-item text={text}>!</todo-item>{/items}</ul>")},
+		"todo-item.stache": {Data: []byte("<li>{text}{children}</li>")},
+	}
+	results, err := restache.CompileAll(fsys, []string{"*.stache"}, restache.WithTarget(restache.TargetDOM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := results["todo-list.stache"]
+	if got.Err != nil {
+		t.Fatal(got.Err)
+	}
+	want := restache.DOMRuntime +
+		"import TodoItem from './todo-item.stache';\n" +
+		"export default function TodoList($0) {let { items = [] } = $0;return $mount(($0) => {const $n1 = document.createDocumentFragment();" +
+		`const $n2 = document.createElement("ul");const $p8 = $range($n2, ($0, $1) => {const $n3 = document.createDocumentFragment();` +
+		`const [$n5, $u6] = (($0, $1) => {const $n4 = document.createDocumentFragment();$n4.append("!");const $update = ($0, $1) => {};$update($0, $1);return [$n4, $update];})($0, $1);` +
+		`const $p7 = $component($n3, TodoItem);const $update = ($0, $1) => {$u6($0, $1);$p7({ text: $1.text, children: $n5 });};$update($0, $1);return [$n3, $update];});$n1.append($n2);` +
+		`const $update = ($0) => {({ items = [] } = $0);$p8(items ?? [], $0);};$update($0);return [$n1, $update];}, $0);}`
+	if got.Contents != want {
+		t.Errorf("todo-list.stache mismatch:\nwant:\n%s\ngot:\n%s", want, got.Contents)
+	}
+}
+
+func TestRunDOM(t *testing.T) {
+	files := map[string]string{
+		"todo-list.stache": `{@props title="Todo" items=[]}
+<h1 class="title">{title}</h1>
+<ul>{#items}<todo-item text={text} done={done}>{note}</todo-item>{/items}</ul>
+{^items.length}<p>Nothing to do</p>{/items.length}
+<p>{t "left" count=items.length one="{count} item" other="{count} items"}</p>
+<ol><todo-item text="review">for <b>{title}</b></todo-item></ol>
+<button onClick={clear} disabled={busy}>Clear</button>
+<!-- restache:customElements sl-* -->
+<sl-badge variant="primary">{title}</sl-badge>`,
+		"todo-item.stache": `<li class="{done ? 'done' : ''}"><input type=checkbox checked={done}> {text}{?children} <i>{children}</i>{/children}</li>`,
+		"main.js": `import 'dom';
+import TodoList from './todo-list.stache';
+const cleared = [];
+const list = TodoList({
+  items: [{ key: 1, text: "a < b", done: true, note: "now" }, { key: 2, text: "c", done: false }],
+  busy: true, clear: () => cleared.push(1),
+});
+console.log(String(list.node));
+const button = list.node.childNodes.find((n) => n.tag === "button");
+button.dispatch("click");
+list.update({ title: "Done", items: [{ key: 2, text: "c", done: true }] });
+console.log(String(list.node));
+console.log(cleared.length, list.node.childNodes.includes(button));
+`,
+	}
+	const want = `<h1 class="title">Todo</h1><ul><li class="done"><input type="checkbox" .checked=true> a &lt; b <i>now</i></li><li class=""><input type="checkbox" .checked=false> c</li></ul>` +
+		`<p>2 items</p><ol><li class=""><input type="checkbox" .checked=false> review <i>for <b>Todo</b></i></li></ol><button disabled="">Clear</button><sl-badge variant="primary">Todo</sl-badge>` + "\n" +
+		`<h1 class="title">Done</h1><ul><li class="done"><input type="checkbox" .checked=true> c</li></ul><p>1 item</p>` +
+		`<ol><li class=""><input type="checkbox" .checked=false> review <i>for <b>Done</b></i></li></ol><button>Clear</button><sl-badge variant="primary">Done</sl-badge>` + "\n" +
+		`1 true`
+	if got := runJS(t, files, restache.WithTarget(restache.TargetDOM)); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,50 @@
+package restache_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestCustomElements(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/page.stache": {Data: []byte(`<sl-button variant="primary" class="big" for="x" tabindex="0" onsl-change={save} disabled={busy}>Go</sl-button>
+<!-- restache:customElements my-widget -->
+<my-widget some-prop="1"></my-widget><my-card label-text="x" class="c"></my-card>`)},
+		"app/early.stache": {Data: []byte(`<my-widget></my-widget>`)},
+		"app/bad.stache":   {Data: []byte(`<!-- restache:customElements [ -->`)},
+		"app/MyCard.jsx":   {Data: []byte("export default () => null;")},
+	}
+
+	results, err := restache.CompileAll(fsys, []string{"app"}, restache.WithCustomElements("sl-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "import * as React from 'react';\n" +
+		"import MyCard from './MyCard.jsx';\n" +
+		`export default function Page($0) {return <><sl-button variant="primary" class="big" for="x" tabindex="0" onsl-change={ $0.save } disabled={ $0.busy }>Go</sl-button>` +
+		`<my-widget some-prop="1"></my-widget><MyCard labelText="x" className="c"></MyCard></>;}`
+	if r := results["app/page.stache"]; r.Err != nil || r.Contents != want {
+		t.Errorf("want:\n%s\ngot:\n%s\n%v", want, r.Contents, r.Err)
+	}
+	// The pragma only applies to the tags after it.
+	if r := results["app/early.stache"]; r.Err == nil {
+		t.Errorf("early: expected a resolution error, got:\n%s", r.Contents)
+	}
+	var serr *restache.SyntaxError
+	if r := results["app/bad.stache"]; !errors.As(r.Err, &serr) || serr.Msg != `invalid custom element pattern "["` {
+		t.Errorf("bad: got %v, want an invalid pattern error", r.Err)
+	}
+
+	results, err = restache.CompileAll(fsys, []string{"app/page.stache"}, restache.WithCustomElements("sl-*"), restache.WithTarget(restache.TargetDOM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results["app/page.stache"]; r.Err != nil || !strings.Contains(r.Contents, `document.createElement("sl-button")`) ||
+		!strings.Contains(r.Contents, `$on($n2, "sl-change", $0.save)`) {
+		t.Errorf("dom: got:\n%s\n%v", r.Contents, r.Err)
+	}
+}
@@ -0,0 +1,529 @@
+package restache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Expr is a node of the expression AST held by variables, attribute
+// expressions and sections.
+//
+// The grammar, from lowest to highest precedence:
+//
+//	expr    = cond { "|" ident { primary } }
+//	cond    = nullish [ "?" cond ":" cond ]
+//	nullish = or { "??" or }
+//	or      = and { "||" and }
+//	and     = eq { "&&" eq }
+//	eq      = rel { ( "==" | "!=" | "===" | "!==" ) rel }
+//	rel     = unary { ( "<" | "<=" | ">" | ">=" ) unary }
+//	unary   = "!" unary | primary
+//	primary = path | ident "(" [ expr { "," expr } ] ")" | literal | "@ref" | "(" expr ")"
+//	path    = ident { "." ident }
+//	literal = string | number | "true" | "false" | "null" | "undefined" | "[]" | "{}"
+//
+// A filter application x | f a b is equivalent to the call f(x, a, b).
+type Expr interface {
+	expr()
+}
+
+// PathExpr is a dotted property path resolved against the current scope.
+type PathExpr struct {
+	Parts []string
+}
+
+type LiteralKind uint8
+
+const (
+	StringLiteral LiteralKind = iota
+	NumberLiteral
+	BoolLiteral
+	NullLiteral
+	UndefinedLiteral
+	ArrayLiteral  // the empty array
+	ObjectLiteral // the empty object
+)
+
+// LiteralExpr is a constant. Value holds its JavaScript source text,
+// including quotes for strings.
+type LiteralExpr struct {
+	Kind  LiteralKind
+	Value string
+}
+
+// UnaryExpr is a prefix operation; Op is always "!".
+type UnaryExpr struct {
+	Op string
+	X  Expr
+}
+
+// BinaryExpr is a comparison or logical operation.
+type BinaryExpr struct {
+	Op   string
+	X, Y Expr
+}
+
+// CondExpr is a ternary conditional.
+type CondExpr struct {
+	Cond, Then, Else Expr
+}
+
+// CallExpr calls an imported helper or filter.
+type CallExpr struct {
+	Func string
+	Args []Expr
+	Pipe bool // written as Args[0] | Func Args[1:]
+}
+
+// RefExpr is the ref received by a component wrapped in forwardRef.
+type RefExpr struct{}
+
+func (*PathExpr) expr()    {}
+func (*LiteralExpr) expr() {}
+func (*UnaryExpr) expr()   {}
+func (*BinaryExpr) expr()  {}
+func (*CondExpr) expr()    {}
+func (*CallExpr) expr()    {}
+func (*RefExpr) expr()     {}
+
+func (e *PathExpr) String() string {
+	return strings.Join(e.Parts, ".")
+}
+
+var (
+	errEmptyExpr      = errors.New("empty expression")
+	errUnterminated   = errors.New("unterminated string literal")
+	errMissingFilter  = errors.New("missing filter name after '|'")
+	errUnexpectedChar = errors.New("unexpected character")
+)
+
+// ParseExpr parses s as a template expression.
+func ParseExpr(s string) (Expr, error) {
+	p := &exprParser{lex: exprLexer{src: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, errEmptyExpr
+	}
+	e, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return e, nil
+}
+
+type exprTokenKind uint8
+
+const (
+	tokEOF exprTokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	val  string
+}
+
+type exprLexer struct {
+	src string
+	pos int
+}
+
+// operators lists punctuation, longest first so that prefixes match last.
+var operators = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||", "??",
+	"[]", "{}",
+	"!", "<", ">", "?", ":", "|", "(", ")", ",", ".", "=", "@",
+}
+
+func (l *exprLexer) next() (exprToken, error) {
+	for l.pos < len(l.src) && spaceTable[l.src[l.pos]] {
+		l.pos++
+	}
+	if l.pos == len(l.src) {
+		return exprToken{kind: tokEOF}, nil
+	}
+	start := l.pos
+	c := l.src[start]
+	switch {
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+			l.pos++
+		}
+		return exprToken{tokIdent, l.src[start:l.pos]}, nil
+
+	case isDigit(c) || (c == '-' && start+1 < len(l.src) && isDigit(l.src[start+1])):
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		if l.pos+1 < len(l.src) && l.src[l.pos] == '.' && isDigit(l.src[l.pos+1]) {
+			l.pos++
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+		}
+		return exprToken{tokNumber, l.src[start:l.pos]}, nil
+
+	case c == '"' || c == '\'':
+		for l.pos++; l.pos < len(l.src) && l.src[l.pos] != c; l.pos++ {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+		}
+		if l.pos >= len(l.src) {
+			return exprToken{}, errUnterminated
+		}
+		l.pos++
+		return exprToken{tokString, l.src[start:l.pos]}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[start:], op) {
+			l.pos += len(op)
+			return exprToken{tokOp, op}, nil
+		}
+	}
+	return exprToken{}, errUnexpectedChar
+}
+
+type exprParser struct {
+	lex exprLexer
+	tok exprToken
+}
+
+func (p *exprParser) next() (err error) {
+	p.tok, err = p.lex.next()
+	return
+}
+
+func (p *exprParser) is(op string) bool {
+	return p.tok.kind == tokOp && p.tok.val == op
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.is(op) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *exprParser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return errors.New("unexpected end of expression")
+	}
+	return errors.New("unexpected " + p.tok.val)
+}
+
+func (p *exprParser) parsePipe() (Expr, error) {
+	x, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("|") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokIdent {
+			return nil, errMissingFilter
+		}
+		call := &CallExpr{Func: p.tok.val, Args: []Expr{x}, Pipe: true}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for p.tok.kind != tokEOF && !p.is("|") && !p.is(")") && !p.is(",") {
+			arg, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+		x = call
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseCond() (Expr, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("?") {
+		return cond, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	then, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	return &CondExpr{Cond: cond, Then: then, Else: els}, nil
+}
+
+// binaryLevels lists binary operators by ascending precedence.
+var binaryLevels = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!=", "===", "!=="},
+	{"<", "<=", ">", ">="},
+}
+
+func (p *exprParser) parseBinary(level int) (Expr, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && slices.Contains(binaryLevels[level], p.tok.val) {
+		op := p.tok.val
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseUnary() (Expr, error) {
+	if p.is("!") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "!", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber, tokString:
+		kind := NumberLiteral
+		if tok.kind == tokString {
+			kind = StringLiteral
+		}
+		return &LiteralExpr{Kind: kind, Value: tok.val}, p.next()
+
+	case tokIdent:
+		switch tok.val {
+		case "true", "false":
+			return &LiteralExpr{Kind: BoolLiteral, Value: tok.val}, p.next()
+		case "null":
+			return &LiteralExpr{Kind: NullLiteral, Value: tok.val}, p.next()
+		case "undefined":
+			return &LiteralExpr{Kind: UndefinedLiteral, Value: tok.val}, p.next()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.is("(") {
+			return p.parseCall(tok.val)
+		}
+		path := &PathExpr{Parts: []string{tok.val}}
+		for p.is(".") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent {
+				return nil, p.unexpected()
+			}
+			path.Parts = append(path.Parts, p.tok.val)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return path, nil
+
+	case tokOp:
+		switch tok.val {
+		case "[]":
+			return &LiteralExpr{Kind: ArrayLiteral, Value: tok.val}, p.next()
+		case "{}":
+			return &LiteralExpr{Kind: ObjectLiteral, Value: tok.val}, p.next()
+		case "@":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent || p.tok.val != "ref" {
+				return nil, p.unexpected()
+			}
+			return &RefExpr{}, p.next()
+		}
+		if tok.val == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			x, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	}
+	return nil, p.unexpected()
+}
+
+func (p *exprParser) parseCall(name string) (Expr, error) {
+	call := &CallExpr{Func: name}
+	if err := p.next(); err != nil { // skip '('
+		return nil, err
+	}
+	for !p.is(")") {
+		if len(call.Args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+	}
+	return call, p.next()
+}
+
+// exprPrec returns the JavaScript precedence of e.
+func exprPrec(e Expr) int {
+	switch e := e.(type) {
+	case *CondExpr:
+		return 2
+	case *BinaryExpr:
+		switch e.Op {
+		case "??", "||":
+			return 3
+		case "&&":
+			return 4
+		case "<", "<=", ">", ">=":
+			return 9
+		default:
+			return 8
+		}
+	case *UnaryExpr:
+		return 14
+	case *CallExpr:
+		return 17
+	}
+	return 20
+}
+
+// walkExpr calls fn for e and each of its subexpressions, in pre-order.
+func walkExpr(e Expr, fn func(Expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch e := e.(type) {
+	case *UnaryExpr:
+		walkExpr(e.X, fn)
+	case *BinaryExpr:
+		walkExpr(e.X, fn)
+		walkExpr(e.Y, fn)
+	case *CondExpr:
+		walkExpr(e.Cond, fn)
+		walkExpr(e.Then, fn)
+		walkExpr(e.Else, fn)
+	case *CallExpr:
+		for _, arg := range e.Args {
+			walkExpr(arg, fn)
+		}
+	case *styleExpr:
+		for _, c := range e.Classes {
+			walkExpr(c, fn)
+		}
+		walkExpr(e.Style, fn)
+	}
+}
+
+// leadingPath returns the leftmost path in e, or nil if there is none.
+func leadingPath(e Expr) *PathExpr {
+	switch e := e.(type) {
+	case *PathExpr:
+		return e
+	case *UnaryExpr:
+		return leadingPath(e.X)
+	case *BinaryExpr:
+		return leadingPath(e.X)
+	case *CondExpr:
+		return leadingPath(e.Cond)
+	case *CallExpr:
+		if len(e.Args) > 0 {
+			return leadingPath(e.Args[0])
+		}
+	}
+	return nil
+}
+
+// extractFilterNames returns the name of every filter or helper called in the
+// tree rooted at n, without duplicates, in depth-first (pre-order) order.
+func (n *Node) extractFilterNames() []string {
+	var (
+		seen  = make(map[string]struct{})
+		out   []string
+		stack nodeStack
+	)
+	add := func(e Expr) {
+		if call, ok := e.(*CallExpr); ok {
+			if _, ok := seen[call.Func]; !ok {
+				seen[call.Func] = struct{}{}
+				out = append(out, call.Func)
+			}
+		}
+	}
+	if n.FirstChild != nil {
+		stack = append(stack, n.FirstChild)
+	}
+	for len(stack) > 0 {
+		c := stack.pop()
+		for c != nil {
+			walkExpr(c.Expr, add)
+			for _, a := range c.Attr {
+				walkExpr(a.Expr, add)
+			}
+			if next := c.NextSibling; next != nil {
+				stack = append(stack, next)
+			}
+			c = c.FirstChild
+		}
+	}
+	return out
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -0,0 +1,53 @@
+package restache
+
+import "slices"
+
+// Filter is a formatting function applied to a value with the pipe syntax,
+// as in {price | currency} or {createdAt | date "short"}.
+//
+// Deprecated: filters are parsed into Expr as calls, so x | f a is the
+// CallExpr f(x, a). Filter only describes chains whose arguments are
+// literals and paths.
+type Filter struct {
+	Name string
+	Args []string // literal source text or variable paths
+}
+
+// filterChain returns the filters piped to the head of e, in order, or nil
+// if e is not such a chain.
+func filterChain(e Expr) []Filter {
+	var chain []Filter
+	for {
+		c, ok := e.(*CallExpr)
+		if !ok || !c.Pipe {
+			break
+		}
+		f := Filter{Name: c.Func, Args: []string{}}
+		for _, arg := range c.Args[1:] {
+			switch arg := arg.(type) {
+			case *LiteralExpr:
+				f.Args = append(f.Args, arg.Value)
+			case *PathExpr:
+				f.Args = append(f.Args, arg.String())
+			default:
+				return nil
+			}
+		}
+		chain = append(chain, f)
+		e = c.Args[0]
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// setFilters sets the deprecated Filters of n and its descendants from
+// their expressions.
+func (n *Node) setFilters() {
+	n.Filters = filterChain(n.Expr)
+	for i, a := range n.Attr {
+		n.Attr[i].Filters = filterChain(a.Expr)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		c.setFilters()
+	}
+}
@@ -0,0 +1,1245 @@
+package restache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"html"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrGoUnnamed   = errors.New("component has no name")
+	ErrGoComponent = errors.New("unknown component")
+	ErrGoExpr      = errors.New("expression cannot be compiled to Go")
+)
+
+// GenerateGo writes the source of a Go file of package pkg that renders the
+// components of roots to HTML, as React renders them on the server. Each
+// root must be named by its Data, like the default export of a compiled
+// template; its named components are generated too.
+//
+// For every component Name, the file declares a NameProps struct, whose
+// fields are inferred from the way the template uses its variables, or
+// typed as declared by its props header, and a function
+//
+//	func RenderName(w io.Writer, p NameProps) error
+//
+// writing its HTML to w. Component tags call the function of the component
+// they name among those generated. A trailing .length is the len of a
+// string or slice. Filters and helpers call the Go functions of the same
+// name, which the package is expected to declare.
+// Event handlers, keys and refs are left out, and a script's names cannot
+// be used outside of ranges. Declared defaults are applied to zero strings and numbers.
+func GenerateGo(w io.Writer, pkg string, roots []*Node) error {
+	g := &goGen{comps: make(map[string]*goComp)}
+	for _, root := range roots {
+		names := root.scriptNames()
+		if body := root.body(); body != nil {
+			if root.Data == "" {
+				return ErrGoUnnamed
+			}
+			if err := g.add(root.Data, root, body, names); err != nil {
+				return err
+			}
+		}
+		for _, c := range root.components() {
+			if err := g.add(c.Data, c, c.body(), c.scriptNames()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range g.order {
+		if err := g.infer(c); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	for _, c := range g.order {
+		g.nameTypes(c.props, c.name+"Props", c.name)
+	}
+	var body bytes.Buffer
+	for _, c := range g.order {
+		g.writeTypes(&body, c.props)
+	}
+	for _, c := range g.order {
+		if err := g.writeComponent(&body, c); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by restache gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	imports := []string{"fmt", "html", "io", "strconv"}
+	if g.usesHTML {
+		imports = append(imports, "html/template")
+	}
+	if g.usesBuilder {
+		imports = append(imports, "strings")
+	}
+	for _, imp := range imports {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	src.WriteString(")\n\n")
+	src.Write(body.Bytes())
+	src.WriteString(goRuntime)
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// goRuntime is included in every generated file.
+const goRuntime = `
+type restacheWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *restacheWriter) s(s string) {
+	if w.err == nil {
+		_, w.err = io.WriteString(w.w, s)
+	}
+}
+
+func (w *restacheWriter) text(s string) {
+	w.s(html.EscapeString(s))
+}
+
+// restacheString formats v as React renders it.
+func restacheString(v any) string {
+	switch v := v.(type) {
+	case nil, bool:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// restacheTruthy reports whether v is truthy in JavaScript.
+func restacheTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	}
+	return true
+}
+`
+
+type goKind uint8
+
+// Kinds of values, in increasing order of precedence when a value is used
+// in several ways; structs and slices take precedence over all of them.
+const (
+	kindUnknown goKind = iota
+	kindAny
+	kindBool
+	kindString
+	kindInt
+	kindFloat
+	kindHTML
+	kindFunc
+	kindStruct
+	kindSlice
+)
+
+// A goType is the Go type inferred for a value.
+type goType struct {
+	kind   goKind
+	fixed  bool   // declared by a props header
+	name   string // of a struct
+	fields []*goField
+	elem   *goType // of a slice
+}
+
+type goField struct {
+	prop string
+	typ  *goType
+}
+
+func (t *goType) hint(k goKind) {
+	if t.fixed || t.kind >= kindStruct || k <= t.kind {
+		return
+	}
+	t.kind = k
+}
+
+// field returns the type of the field prop of the struct t, adding it if
+// needed.
+func (t *goType) field(prop string) (*goType, error) {
+	if t.kind != kindStruct {
+		if t.fixed || t.kind == kindSlice {
+			return nil, ErrGoExpr
+		}
+		t.kind = kindStruct
+	}
+	for _, f := range t.fields {
+		if f.prop == prop {
+			return f.typ, nil
+		}
+	}
+	ft := &goType{}
+	if prop == "children" {
+		ft.kind, ft.fixed = kindHTML, true
+	}
+	t.fields = append(t.fields, &goField{prop: prop, typ: ft})
+	return ft, nil
+}
+
+// length returns the type of the length of the string or slice t, making t
+// a slice if its kind is not known yet.
+func (t *goType) length() (*goType, error) {
+	switch t.kind {
+	case kindString, kindSlice:
+	case kindUnknown, kindAny, kindBool:
+		if t.fixed {
+			return nil, ErrGoExpr
+		}
+		t.kind = kindSlice
+	default:
+		return nil, ErrGoExpr
+	}
+	if t.kind == kindSlice && t.elem == nil {
+		t.elem = &goType{}
+	}
+	return &goType{kind: kindInt, fixed: true}, nil
+}
+
+func (t *goType) lookup(prop string) *goType {
+	for _, f := range t.fields {
+		if f.prop == prop {
+			return f.typ
+		}
+	}
+	return nil
+}
+
+// String returns the Go type t, with structs behind pointers.
+func (t *goType) String() string {
+	switch t.kind {
+	case kindBool:
+		return "bool"
+	case kindString:
+		return "string"
+	case kindInt:
+		return "int"
+	case kindFloat:
+		return "float64"
+	case kindHTML:
+		return "template.HTML"
+	case kindStruct:
+		return "*" + t.name
+	case kindSlice:
+		if t.elem.kind == kindStruct {
+			return "[]" + t.elem.name
+		}
+		return "[]" + t.elem.String()
+	}
+	return "any"
+}
+
+func (t *goType) zero() string {
+	switch t.kind {
+	case kindBool:
+		return "false"
+	case kindString, kindHTML:
+		return `""`
+	case kindInt, kindFloat:
+		return "0"
+	}
+	return "nil"
+}
+
+var declaredKinds = map[string]goKind{
+	"any":      kindAny,
+	"array":    kindSlice,
+	"boolean":  kindBool,
+	"function": kindFunc,
+	"node":     kindHTML,
+	"number":   kindFloat,
+	"object":   kindStruct,
+	"string":   kindString,
+}
+
+type goGen struct {
+	comps map[string]*goComp
+	order []*goComp
+
+	usesHTML    bool // whether the file uses template.HTML
+	usesBuilder bool // whether it renders children into a strings.Builder
+}
+
+// A goComp is a component being generated.
+type goComp struct {
+	name  string
+	node  *Node
+	body  *Node // or nil
+	names []string
+	props *goType
+	state int // 0 before inference, 1 during it, 2 after it
+}
+
+func (g *goGen) add(name string, n, body *Node, names []string) error {
+	if _, ok := g.comps[name]; ok {
+		return fmt.Errorf("duplicate component %q", name)
+	}
+	c := &goComp{name: name, node: n, body: body, names: names}
+	g.comps[name] = c
+	g.order = append(g.order, c)
+	return nil
+}
+
+// component returns the generated component the element n calls, or nil
+// if n is an HTML element or a fragment.
+func (g *goGen) component(n *Node) (*goComp, error) {
+	if n.DataAtom != 0 || n.Data == "" || n.Data == keyedFragment || n.CustomElement {
+		return nil, nil
+	}
+	c, ok := g.comps[pascalize(n.Data)]
+	if !ok {
+		return nil, fmt.Errorf("%w <%s>", ErrGoComponent, n.Data)
+	}
+	return c, nil
+}
+
+// infer infers the props of c, after those of the components it calls.
+func (g *goGen) infer(c *goComp) error {
+	if c.state != 0 {
+		return nil // done, or called recursively
+	}
+	c.state = 1
+	c.props = &goType{kind: kindStruct}
+	for _, p := range c.node.Props {
+		t, err := c.props.field(p.Name)
+		if err != nil {
+			return err
+		}
+		if k, ok := declaredKinds[p.Type]; ok {
+			t.kind = k
+			t.fixed = k < kindStruct
+		}
+	}
+	if c.body != nil {
+		if err := g.inferNode(c, c.props, c.body); err != nil {
+			return err
+		}
+	}
+	c.state = 2
+	return nil
+}
+
+func (g *goGen) inferChildren(c *goComp, scope *goType, p *Node) error {
+	for n := p.FirstChild; n != nil; n = n.NextSibling {
+		if err := g.inferNode(c, scope, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *goGen) inferNode(c *goComp, scope *goType, n *Node) error {
+	switch n.Type {
+	case VariableNode, UnescapedNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		want := kindString
+		if n.Type == UnescapedNode {
+			want = kindHTML
+		}
+		return g.inferExpr(c, scope, e, want)
+	case WhenNode, UnlessNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		if err := g.inferExpr(c, scope, e, kindBool); err != nil {
+			return err
+		}
+		return g.inferChildren(c, scope, n)
+	case RangeNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		t, err := g.inferPath(c, scope, e)
+		if err != nil {
+			return err
+		}
+		if t.kind != kindSlice {
+			if t.fixed || t.kind == kindStruct {
+				return ErrGoExpr
+			}
+			t.kind = kindSlice
+		}
+		if t.elem == nil {
+			t.elem = &goType{}
+		}
+		return g.inferChildren(c, t.elem, n)
+	case TranslateNode:
+		_, params, err := n.message()
+		if err != nil {
+			return err
+		}
+		for _, a := range params {
+			if !a.IsExpr {
+				continue
+			}
+			e, err := a.expr()
+			if err != nil {
+				return err
+			}
+			want := kindString
+			if a.Key == "count" {
+				want = kindInt
+			}
+			if err := g.inferExpr(c, scope, e, want); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ElementNode:
+		callee, err := g.component(n)
+		if err != nil {
+			return err
+		}
+		if callee != nil {
+			if err := g.infer(callee); err != nil {
+				return err
+			}
+		}
+		for _, a := range n.Attr {
+			if !a.IsExpr || skipGoAttr(n, a) {
+				continue
+			}
+			e, err := a.expr()
+			if err != nil {
+				return err
+			}
+			want := kindString
+			if callee != nil {
+				if t := callee.props.lookup(attrKey(n, a)); t != nil && t.kind < kindStruct {
+					want = t.kind
+				} else {
+					want = kindUnknown
+				}
+			} else if isGoBoolAttr(a) {
+				want = kindBool
+			}
+			if err := g.inferExpr(c, scope, e, want); err != nil {
+				return err
+			}
+		}
+		return g.inferChildren(c, scope, n)
+	}
+	return nil
+}
+
+// inferPath returns the type of the value of the path e.
+func (g *goGen) inferPath(c *goComp, scope *goType, e Expr) (*goType, error) {
+	path, ok := e.(*PathExpr)
+	if !ok || scope == c.props && slices.Contains(c.names, path.Parts[0]) {
+		return nil, ErrGoExpr
+	}
+	t := scope
+	for i, part := range path.Parts {
+		if part == "length" && i == len(path.Parts)-1 && t.kind != kindStruct && t != scope {
+			return t.length()
+		}
+		var err error
+		if t, err = t.field(part); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// inferExpr records that e is used as a value of kind want.
+func (g *goGen) inferExpr(c *goComp, scope *goType, e Expr, want goKind) error {
+	switch e := e.(type) {
+	case *PathExpr:
+		t, err := g.inferPath(c, scope, e)
+		if err != nil {
+			return err
+		}
+		t.hint(want)
+		return nil
+	case *LiteralExpr:
+		return nil
+	case *UnaryExpr:
+		return g.inferExpr(c, scope, e.X, kindBool)
+	case *BinaryExpr:
+		switch e.Op {
+		case "&&", "||", "??":
+			if err := g.inferExpr(c, scope, e.X, want); err != nil {
+				return err
+			}
+			return g.inferExpr(c, scope, e.Y, want)
+		case "<", "<=", ">", ">=":
+			kx, ky := literalKind(e.Y), literalKind(e.X)
+			if kx == kindUnknown && ky == kindUnknown {
+				kx, ky = kindFloat, kindFloat
+			}
+			if err := g.inferExpr(c, scope, e.X, kx); err != nil {
+				return err
+			}
+			return g.inferExpr(c, scope, e.Y, ky)
+		}
+		if err := g.inferExpr(c, scope, e.X, literalKind(e.Y)); err != nil {
+			return err
+		}
+		return g.inferExpr(c, scope, e.Y, literalKind(e.X))
+	case *CondExpr:
+		if err := g.inferExpr(c, scope, e.Cond, kindBool); err != nil {
+			return err
+		}
+		if err := g.inferExpr(c, scope, e.Then, want); err != nil {
+			return err
+		}
+		return g.inferExpr(c, scope, e.Else, want)
+	case *CallExpr:
+		for _, arg := range e.Args {
+			if err := g.inferExpr(c, scope, arg, kindAny); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ErrGoExpr
+}
+
+// literalKind returns the kind of the literal e, or kindUnknown if e is not a
+// literal.
+func literalKind(e Expr) goKind {
+	lit, ok := e.(*LiteralExpr)
+	if !ok {
+		return kindUnknown
+	}
+	switch lit.Kind {
+	case StringLiteral:
+		return kindString
+	case NumberLiteral:
+		if strings.Contains(lit.Value, ".") {
+			return kindFloat
+		}
+		return kindInt
+	case BoolLiteral:
+		return kindBool
+	}
+	return kindUnknown
+}
+
+// skipGoAttr reports whether the attribute a of n is left out of the HTML.
+func skipGoAttr(n *Node, a Attribute) bool {
+	key := attrKey(n, a)
+	return key == "key" || key == "ref" || len(key) > 2 && strings.HasPrefix(key, "on") && a.IsExpr
+}
+
+func isGoBoolAttr(a Attribute) bool {
+	_, ok := boolAttrs[a.KeyAtom]
+	return ok && a.KeyAtom != 0
+}
+
+// nameTypes names the struct types reachable from t, which is named name.
+func (g *goGen) nameTypes(t *goType, name, base string) {
+	switch t.kind {
+	case kindUnknown:
+		t.kind = kindAny
+	case kindHTML:
+		g.usesHTML = true
+	case kindStruct:
+		t.name = name
+		for _, f := range t.fields {
+			g.nameTypes(f.typ, base+goFieldName(f.prop), base+goFieldName(f.prop))
+		}
+	case kindSlice:
+		if t.elem == nil {
+			t.elem = &goType{kind: kindAny}
+		}
+		g.nameTypes(t.elem, name+"Item", name+"Item")
+	}
+}
+
+func (g *goGen) writeTypes(w *bytes.Buffer, t *goType) {
+	switch t.kind {
+	case kindSlice:
+		g.writeTypes(w, t.elem)
+		return
+	case kindStruct:
+	default:
+		return
+	}
+	fmt.Fprintf(w, "type %s struct {\n", t.name)
+	for _, f := range t.fields {
+		fmt.Fprintf(w, "\t%s %s `json:%q`\n", goFieldName(f.prop), f.typ, f.prop+",omitempty")
+	}
+	w.WriteString("}\n\n")
+	for _, f := range t.fields {
+		g.writeTypes(w, f.typ)
+	}
+}
+
+// goFieldName returns the exported name of the field for prop.
+func goFieldName(prop string) string {
+	r := []rune(prop)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (g *goGen) writeComponent(w *bytes.Buffer, c *goComp) error {
+	fmt.Fprintf(w, "// Render%s writes the HTML of the %s component.\n", c.name, c.name)
+	fmt.Fprintf(w, "func Render%s(w io.Writer, p %s) error {\n", c.name, c.props.name)
+	fmt.Fprintf(w, "\trw := &restacheWriter{w: w}\n\trender%s(rw, &p)\n\treturn rw.err\n}\n\n", c.name)
+	e := &goEmitter{g: g, c: c, scopes: []goScope{{c.props, "p"}}}
+	for _, p := range c.node.Props {
+		e.writeDefault(p)
+	}
+	if c.body != nil {
+		if err := e.node(c.body); err != nil {
+			return err
+		}
+	}
+	e.flush()
+	fmt.Fprintf(w, "func render%s(w *restacheWriter, p *%s) {\n", c.name, c.props.name)
+	w.Write(e.out.Bytes())
+	w.WriteString("}\n\n")
+	return nil
+}
+
+// A goEmitter writes the statements of a render function.
+type goEmitter struct {
+	g       *goGen
+	c       *goComp
+	out     bytes.Buffer
+	pending strings.Builder // static HTML not written yet
+	scopes  []goScope
+	buffers int // children buffers declared
+}
+
+type goScope struct {
+	typ  *goType
+	expr string
+}
+
+// html queues the static HTML s.
+func (e *goEmitter) html(s string) {
+	e.pending.WriteString(s)
+}
+
+func (e *goEmitter) flush() {
+	if e.pending.Len() > 0 {
+		fmt.Fprintf(&e.out, "w.s(%s)\n", strconv.Quote(e.pending.String()))
+		e.pending.Reset()
+	}
+}
+
+func (e *goEmitter) stmt(format string, args ...any) {
+	e.flush()
+	fmt.Fprintf(&e.out, format+"\n", args...)
+}
+
+func (e *goEmitter) writeDefault(p Prop) {
+	lit, ok := p.Default.(*LiteralExpr)
+	if !ok {
+		return
+	}
+	t := e.c.props.lookup(p.Name)
+	var val string
+	switch {
+	case lit.Kind == StringLiteral && t.kind == kindString:
+		val = goString(lit.Value)
+	case lit.Kind == NumberLiteral && (t.kind == kindFloat || t.kind == kindInt && literalKind(lit) == kindInt):
+		val = lit.Value
+	default:
+		return
+	}
+	e.stmt("if p.%s == %s {\np.%s = %s\n}", goFieldName(p.Name), t.zero(), goFieldName(p.Name), val)
+}
+
+func (e *goEmitter) children(p *Node) error {
+	for n := p.FirstChild; n != nil; n = n.NextSibling {
+		if err := e.node(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *goEmitter) node(n *Node) error {
+	switch n.Type {
+	case ErrorNode:
+		return ErrErrorNode
+	case TextNode:
+		if n.isRawText() {
+			e.html(n.Data)
+		} else {
+			e.html(html.EscapeString(n.Data))
+		}
+		return nil
+	case ElementNode:
+		return e.element(n)
+	case VariableNode:
+		x, err := n.expr()
+		if err != nil {
+			return err
+		}
+		return e.print(x)
+	case WhenNode, UnlessNode:
+		x, err := n.expr()
+		if err != nil {
+			return err
+		}
+		if n.Type == UnlessNode {
+			x = &UnaryExpr{Op: "!", X: x}
+		}
+		cond, err := e.truthy(x)
+		if err != nil {
+			return err
+		}
+		e.stmt("if %s {", cond)
+		if err := e.children(n); err != nil {
+			return err
+		}
+		e.stmt("}")
+		return nil
+	case RangeNode:
+		x, err := n.expr()
+		if err != nil {
+			return err
+		}
+		list, t, err := e.expr(x)
+		if err != nil {
+			return err
+		}
+		if t.kind != kindSlice {
+			return ErrGoExpr
+		}
+		item := fmt.Sprintf("it%d", len(e.scopes))
+		e.stmt("for _, %s := range %s {", item, list)
+		e.scopes = append(e.scopes, goScope{t.elem, item})
+		err = e.children(n)
+		e.scopes = e.scopes[:len(e.scopes)-1]
+		if err != nil {
+			return err
+		}
+		e.stmt("}")
+		return nil
+	case TranslateNode:
+		return e.translate(n)
+	case CommentNode:
+		return nil // React renders no comments
+	case UnescapedNode:
+		return ErrSoleChildOnly
+	}
+	return ErrUnknownNode
+}
+
+func (e *goEmitter) element(n *Node) error {
+	callee, err := e.g.component(n)
+	if err != nil {
+		return err
+	}
+	if callee != nil {
+		return e.call(n, callee)
+	}
+	if n.DataAtom == 0 && !n.CustomElement {
+		return e.children(n) // a fragment
+	}
+	tag := n.TagName()
+	e.html("<" + tag)
+	for _, a := range n.Attr {
+		if err := e.attribute(n, a); err != nil {
+			return err
+		}
+	}
+	e.html(">")
+	if _, ok := voidElements[n.DataAtom]; ok {
+		if n.FirstChild != nil {
+			return ErrVoidChildren
+		}
+		return nil
+	}
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil {
+		x, err := c.expr()
+		if err != nil {
+			return err
+		}
+		v, t, err := e.expr(x)
+		if err != nil {
+			return err
+		}
+		switch t.kind {
+		case kindHTML:
+			e.stmt("w.s(string(%s))", v)
+		case kindString:
+			e.stmt("w.s(%s)", v)
+		default:
+			e.stmt("w.s(restacheString(%s))", v)
+		}
+	} else if err := e.children(n); err != nil {
+		return err
+	}
+	e.html("</" + tag + ">")
+	return nil
+}
+
+func (e *goEmitter) attribute(n *Node, a Attribute) error {
+	if skipGoAttr(n, a) {
+		return nil
+	}
+	name := htmlAttrName(n, a)
+	if !a.IsExpr {
+		if a.Val == "" && isGoBoolAttr(a) {
+			e.html(" " + name)
+		} else {
+			e.html(" " + name + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		return nil
+	}
+	x, err := a.expr()
+	if err != nil {
+		return err
+	}
+	if isGoBoolAttr(a) {
+		cond, err := e.truthy(x)
+		if err != nil {
+			return err
+		}
+		e.stmt("if %s {\nw.s(%s)\n}", cond, strconv.Quote(" "+name))
+		return nil
+	}
+	if name == "style" {
+		return ErrGoExpr // an object in React
+	}
+	v, t, err := e.expr(x)
+	if err != nil {
+		return err
+	}
+	e.html(" " + name + `="`)
+	if t.kind == kindHTML {
+		e.stmt("w.text(string(%s))", v) // markup is text in an attribute
+	} else if err := e.value(v, t); err != nil {
+		return err
+	}
+	e.html(`"`)
+	return nil
+}
+
+// call writes a call of the component callee with the attributes and
+// children of n as props.
+func (e *goEmitter) call(n *Node, callee *goComp) error {
+	var fields []string
+	for _, a := range n.Attr {
+		key := attrKey(n, a)
+		t := callee.props.lookup(key)
+		if t == nil || skipGoAttr(n, a) {
+			continue // unused by the component
+		}
+		var v string
+		if a.IsExpr {
+			x, err := a.expr()
+			if err != nil {
+				return err
+			}
+			var vt *goType
+			if v, vt, err = e.expr(x); err != nil {
+				return err
+			}
+			if v, err = convertGo(v, vt, t); err != nil {
+				return err
+			}
+		} else {
+			var err error
+			if v, err = goConstant(a, t); err != nil {
+				return err
+			}
+		}
+		fields = append(fields, goFieldName(key)+": "+v)
+	}
+	if n.FirstChild != nil {
+		if callee.props.lookup("children") == nil {
+			return fmt.Errorf("component %s takes no children", callee.name)
+		}
+		e.g.usesBuilder = true
+		e.buffers++
+		buf := fmt.Sprintf("children%d", e.buffers)
+		e.stmt("var %s strings.Builder", buf)
+		e.stmt("{\nw, outer := &restacheWriter{w: &%s}, w", buf)
+		if err := e.children(n); err != nil {
+			return err
+		}
+		e.stmt("if outer.err == nil {\nouter.err = w.err\n}\n}")
+		fields = append(fields, "Children: template.HTML("+buf+".String())")
+	}
+	e.stmt("render%s(w, &%s{%s})", callee.name, callee.props.name, strings.Join(fields, ", "))
+	return nil
+}
+
+// goConstant returns the value of the static attribute a as a constant of
+// type t.
+func goConstant(a Attribute, t *goType) (string, error) {
+	switch t.kind {
+	case kindBool:
+		return "true", nil // present
+	case kindInt:
+		if _, err := strconv.Atoi(a.Val); err == nil {
+			return a.Val, nil
+		}
+	case kindFloat:
+		if _, err := strconv.ParseFloat(a.Val, 64); err == nil {
+			return a.Val, nil
+		}
+	case kindHTML:
+		return "template.HTML(" + strconv.Quote(html.EscapeString(a.Val)) + ")", nil
+	case kindString, kindAny:
+		return strconv.Quote(a.Val), nil
+	}
+	return "", ErrGoExpr
+}
+
+// convertGo converts the value v of type from to the type to.
+func convertGo(v string, from, to *goType) (string, error) {
+	switch {
+	case to.kind == kindAny || from.String() == to.String():
+		return v, nil
+	case to.kind == kindString && from.kind == kindInt:
+		return "strconv.Itoa(" + v + ")", nil
+	case to.kind == kindString && from.kind == kindFloat:
+		return "strconv.FormatFloat(" + v + ", 'f', -1, 64)", nil
+	case to.kind == kindString && from.kind == kindAny:
+		return "restacheString(" + v + ")", nil
+	case to.kind == kindFloat && from.kind == kindInt:
+		return "float64(" + v + ")", nil
+	case to.kind == kindHTML && from.kind == kindString:
+		return "template.HTML(html.EscapeString(" + v + "))", nil
+	case to.kind == kindBool:
+		return truthyGo(v, from), nil
+	}
+	return "", ErrGoExpr
+}
+
+// print writes the value of x as text.
+func (e *goEmitter) print(x Expr) error {
+	v, t, err := e.expr(x)
+	if err != nil {
+		return err
+	}
+	return e.value(v, t)
+}
+
+// value writes the value v of type t as text.
+func (e *goEmitter) value(v string, t *goType) error {
+	switch t.kind {
+	case kindString:
+		e.stmt("w.text(%s)", v)
+	case kindInt:
+		e.stmt("w.s(strconv.Itoa(%s))", v)
+	case kindFloat:
+		e.stmt("w.s(strconv.FormatFloat(%s, 'f', -1, 64))", v)
+	case kindHTML:
+		e.stmt("w.s(string(%s))", v)
+	case kindBool:
+		// React renders nothing for booleans
+	case kindAny:
+		e.stmt("w.text(restacheString(%s))", v)
+	default:
+		return ErrGoExpr
+	}
+	return nil
+}
+
+func (e *goEmitter) truthy(x Expr) (string, error) {
+	switch x := x.(type) {
+	case *UnaryExpr:
+		cond, err := e.truthy(x.X)
+		if err != nil {
+			return "", err
+		}
+		return "!(" + cond + ")", nil
+	case *BinaryExpr:
+		if x.Op == "&&" || x.Op == "||" {
+			l, err := e.truthy(x.X)
+			if err != nil {
+				return "", err
+			}
+			r, err := e.truthy(x.Y)
+			if err != nil {
+				return "", err
+			}
+			return "(" + l + " " + x.Op + " " + r + ")", nil
+		}
+	}
+	v, t, err := e.expr(x)
+	if err != nil {
+		return "", err
+	}
+	return truthyGo(v, t), nil
+}
+
+func truthyGo(v string, t *goType) string {
+	switch t.kind {
+	case kindBool:
+		return v
+	case kindString, kindHTML:
+		return v + ` != ""`
+	case kindInt, kindFloat:
+		return v + " != 0"
+	case kindStruct, kindSlice:
+		return v + " != nil"
+	}
+	return "restacheTruthy(" + v + ")"
+}
+
+var (
+	goAnyType    = &goType{kind: kindAny}
+	goBoolType   = &goType{kind: kindBool}
+	goStringType = &goType{kind: kindString}
+	goIntType    = &goType{kind: kindInt}
+	goFloatType  = &goType{kind: kindFloat}
+)
+
+// expr returns the Go expression evaluating x, and its type.
+func (e *goEmitter) expr(x Expr) (string, *goType, error) {
+	switch x := x.(type) {
+	case *PathExpr:
+		return e.path(x)
+	case *LiteralExpr:
+		switch x.Kind {
+		case StringLiteral:
+			return goString(x.Value), goStringType, nil
+		case NumberLiteral:
+			if literalKind(x) == kindFloat {
+				return x.Value, goFloatType, nil
+			}
+			return x.Value, goIntType, nil
+		case BoolLiteral:
+			return x.Value, goBoolType, nil
+		case NullLiteral, UndefinedLiteral:
+			return "nil", goAnyType, nil
+		}
+	case *UnaryExpr:
+		cond, err := e.truthy(x)
+		return cond, goBoolType, err
+	case *BinaryExpr:
+		return e.binary(x)
+	case *CondExpr:
+		cond, err := e.truthy(x.Cond)
+		if err != nil {
+			return "", nil, err
+		}
+		a, at, err := e.expr(x.Then)
+		if err != nil {
+			return "", nil, err
+		}
+		b, bt, err := e.expr(x.Else)
+		if err != nil {
+			return "", nil, err
+		}
+		t := commonGoType(at, bt)
+		return fmt.Sprintf("func() %s {\nif %s {\nreturn %s\n}\nreturn %s\n}()", t, cond, a, b), t, nil
+	case *CallExpr:
+		args := make([]string, len(x.Args))
+		for i, arg := range x.Args {
+			var err error
+			if args[i], _, err = e.expr(arg); err != nil {
+				return "", nil, err
+			}
+		}
+		return x.Func + "(" + strings.Join(args, ", ") + ")", goAnyType, nil
+	}
+	return "", nil, ErrGoExpr
+}
+
+// path returns the field at the path x, or the zero value of its type if
+// a struct on the way is missing.
+func (e *goEmitter) path(x *PathExpr) (string, *goType, error) {
+	scope := e.scopes[len(e.scopes)-1]
+	v, t := scope.expr, scope.typ
+	var checks []string
+	for i, part := range x.Parts {
+		if part == "length" && i == len(x.Parts)-1 && (t.kind == kindString || t.kind == kindSlice) {
+			v, t = "len("+v+")", goIntType
+			break
+		}
+		if i > 0 && t.kind == kindStruct {
+			checks = append(checks, v+" == nil")
+		}
+		if t = t.lookup(part); t == nil {
+			return "", nil, ErrGoExpr
+		}
+		v += "." + goFieldName(part)
+	}
+	if len(checks) > 0 {
+		v = fmt.Sprintf("func() %s {\nif %s {\nreturn %s\n}\nreturn %s\n}()", t, strings.Join(checks, " || "), t.zero(), v)
+	}
+	return v, t, nil
+}
+
+func (e *goEmitter) binary(x *BinaryExpr) (string, *goType, error) {
+	switch x.Op {
+	case "&&", "||", "??":
+		a, at, err := e.expr(x.X)
+		if err != nil {
+			return "", nil, err
+		}
+		b, bt, err := e.expr(x.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		t := commonGoType(at, bt)
+		var keep string // whether the left operand is the result
+		switch x.Op {
+		case "&&":
+			keep = "!(" + truthyGo("v", at) + ")"
+		case "||":
+			keep = truthyGo("v", at)
+		default:
+			keep = "v != " + at.zero()
+		}
+		return fmt.Sprintf("func() %s {\nif v := %s; %s {\nreturn v\n}\nreturn %s\n}()", t, a, keep, b), t, nil
+	}
+	a, at, err := e.expr(x.X)
+	if err != nil {
+		return "", nil, err
+	}
+	b, bt, err := e.expr(x.Y)
+	if err != nil {
+		return "", nil, err
+	}
+	op := strings.TrimSuffix(x.Op, "=")
+	if x.Op == "===" || x.Op == "!==" || x.Op == "==" || x.Op == "!=" {
+		op = x.Op[:2]
+	}
+	switch {
+	case b == "nil":
+		b = at.zero()
+	case a == "nil":
+		a = bt.zero()
+	case at.kind == kindInt && bt.kind == kindFloat:
+		a = "float64(" + a + ")"
+	case at.kind == kindFloat && bt.kind == kindInt:
+		b = "float64(" + b + ")"
+	case at.kind != bt.kind && (op == "==" || op == "!="):
+		a, b = "restacheString("+a+")", "restacheString("+b+")"
+	case at.kind != bt.kind || at.kind >= kindHTML:
+		return "", nil, ErrGoExpr
+	}
+	if x.Op == "<=" || x.Op == ">=" {
+		op = x.Op
+	}
+	return "(" + a + " " + op + " " + b + ")", goBoolType, nil
+}
+
+// commonGoType returns the type of a value of type a or b.
+func commonGoType(a, b *goType) *goType {
+	if a.String() == b.String() {
+		return a
+	}
+	return goAnyType
+}
+
+// translate writes the default message of the translation n.
+func (e *goEmitter) translate(n *Node) error {
+	m, params, err := n.message()
+	if err != nil {
+		return err
+	}
+	if err := m.checkDefault(); err != nil {
+		return err
+	}
+	if m.Plurals == nil {
+		if m.Text == "" {
+			e.html(html.EscapeString(m.Key))
+			return nil
+		}
+		return e.message(m.Text, params)
+	}
+	i := slices.IndexFunc(params, func(a Attribute) bool { return a.Key == "count" })
+	if !params[i].IsExpr {
+		count, form := params[i].Val, m.Plurals["other"]
+		for i, cat := range []string{"zero", "one", "two"} {
+			if count == strconv.Itoa(i) && m.Plurals[cat] != "" {
+				form = m.Plurals[cat]
+			}
+		}
+		return e.message(form, params)
+	}
+	x, err := params[i].expr()
+	if err != nil {
+		return err
+	}
+	count, t, err := e.expr(x)
+	if err != nil {
+		return err
+	}
+	if count, err = convertGo(count, t, goFloatType); err != nil {
+		return err
+	}
+	e.stmt("switch %s {", count)
+	for i, cat := range []string{"zero", "one", "two"} {
+		if form, ok := m.Plurals[cat]; ok {
+			e.stmt("case %d:", i)
+			if err := e.message(form, params); err != nil {
+				return err
+			}
+		}
+	}
+	e.stmt("default:")
+	if err := e.message(m.Plurals["other"], params); err != nil {
+		return err
+	}
+	e.stmt("}")
+	return nil
+}
+
+// message writes msg with its placeholders replaced by the values of
+// params.
+func (e *goEmitter) message(msg string, params []Attribute) error {
+	for msg != "" {
+		i := strings.IndexByte(msg, '{')
+		j := strings.IndexByte(msg[i+1:], '}') + i + 1
+		if i < 0 || j <= i {
+			break
+		}
+		e.html(html.EscapeString(msg[:i]))
+		name := msg[i+1 : j]
+		k := slices.IndexFunc(params, func(a Attribute) bool { return a.Key == name })
+		switch {
+		case k < 0:
+			e.html(html.EscapeString(msg[i : j+1]))
+		case !params[k].IsExpr:
+			e.html(html.EscapeString(params[k].Val))
+		default:
+			x, err := params[k].expr()
+			if err != nil {
+				return err
+			}
+			if err := e.print(x); err != nil {
+				return err
+			}
+		}
+		msg = msg[j+1:]
+	}
+	e.html(html.EscapeString(msg))
+	return nil
+}
@@ -0,0 +1,193 @@
+package restache_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetsuo/restache"
+)
+
+func generateGo(t *testing.T, templates ...string) (string, error) {
+	t.Helper()
+	var roots []*restache.Node
+	for i := 0; i < len(templates); i += 2 {
+		root := parseNode(t, templates[i+1])
+		root.Data = templates[i]
+		roots = append(roots, root)
+	}
+	var b bytes.Buffer
+	err := restache.GenerateGo(&b, "main", roots)
+	return b.String(), err
+}
+
+func TestGenerateGoProps(t *testing.T) {
+	src, err := generateGo(t, "Fruits", `{@props title="Fruits" limit:number}
+<h1>{title}</h1>
+<ul>{#items}<li class={color}>{name}{?stock > 0} ({stock}){/stock > 0}</li>{/items}</ul>
+{?user.admin}<b>{user.name}</b>{/user.admin}
+<button onClick={buy} disabled={busy}>Buy</button>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type FruitsProps struct {\n\tTitle string            `json:\"title,omitempty\"`\n\tLimit float64           `json:\"limit,omitempty\"`\n\tItems []FruitsItemsItem `json:\"items,omitempty\"`\n\tUser  *FruitsUser       `json:\"user,omitempty\"`\n\tBusy  bool              `json:\"busy,omitempty\"`\n}",
+		"type FruitsItemsItem struct {\n\tColor string `json:\"color,omitempty\"`\n\tName  string `json:\"name,omitempty\"`\n\tStock int    `json:\"stock,omitempty\"`\n}",
+		"type FruitsUser struct {\n\tAdmin bool   `json:\"admin,omitempty\"`\n\tName  string `json:\"name,omitempty\"`\n}",
+		"func RenderFruits(w io.Writer, p FruitsProps) error {",
+		"if p.Title == \"\" {\n\t\tp.Title = \"Fruits\"\n\t}",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated source does not contain\n%s\n\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	for _, tc := range []struct {
+		templates []string
+		err       error
+		msg       string
+	}{
+		{
+			templates: []string{"Card", `<script>const label = "x"</script><p>{label}</p>`},
+			err:       restache.ErrGoExpr,
+		},
+		{
+			templates: []string{"Card", `<p><fancy-box></fancy-box></p>`},
+			err:       restache.ErrGoComponent,
+		},
+		{
+			templates: []string{"Card", `<p>{user}</p><p>{user.name}</p>`},
+			err:       restache.ErrGoExpr,
+		},
+		{
+			templates: []string{"", `<p>hi</p>`},
+			err:       restache.ErrGoUnnamed,
+		},
+		{
+			templates: []string{"Card", `<p>{t "n" count=n few="{count} items" other="{count} items"}</p>`},
+			err:       restache.ErrPluralForm,
+		},
+		{
+			templates: []string{"Card", `<p>{text}</p>`, "Page", `<card>hi</card>`},
+			msg:       "Page: component Card takes no children",
+		},
+		{
+			templates: []string{"Card", `<p>hi</p>`, "Card", `<p>ho</p>`},
+			msg:       `duplicate component "Card"`,
+		},
+	} {
+		_, err := generateGo(t, tc.templates...)
+		if err == nil {
+			t.Errorf("%v: expected an error", tc.templates)
+		} else if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%v: expected %v, got %v", tc.templates, tc.err, err)
+		} else if tc.msg != "" && err.Error() != tc.msg {
+			t.Errorf("%v: expected %q, got %q", tc.templates, tc.msg, err)
+		}
+	}
+}
+
+// goModule writes files to a new module and returns its directory.
+func goModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module cart\n\ngo 1.24\n"
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func goCommand(t *testing.T, dir string, args ...string) ([]byte, error) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a Go program")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command(goTool, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	return cmd.CombinedOutput()
+}
+
+func TestGenerateGoVet(t *testing.T) {
+	src, err := generateGo(t,
+		"Card", `<div class="card">{children}</div>`,
+		"Page", `<div><card>a</card><card>b</card></div>
+<ul>{#rows}<li><card><card>{name}</card></card></li><card>{name}</card>{/rows}</ul>`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := goModule(t, map[string]string{
+		"views_gen.go": strings.Replace(src, "package main", "package cart", 1),
+	})
+	if out, err := goCommand(t, dir, "vet", "."); err != nil {
+		t.Fatalf("%v\n%s\n%s", err, out, src)
+	}
+}
+
+func TestGenerateGoRun(t *testing.T) {
+	src, err := generateGo(t,
+		"Cart", `{@props title="Cart"}<h1 class="title">{title}</h1>
+<ul>{#items}<cart-item key={id} name={name} qty={qty} gift={gift}>{note}</cart-item>{/items}</ul>
+{^items}<p>Empty</p>{/items}
+<p data-count={items.length}>{items.length} lines</p>
+<p title={promo}>{&promo}</p>
+<p>{t "total" count=qty one="{count} item" other="{count} items"} · {money(total)}</p>
+{?user.admin}<a href={user.url}>admin</a>{/user.admin}
+<input disabled={locked} value="{coupon || 'none'}">`,
+		"CartItem", `<li class="{gift ? 'gift' : 'plain'}">{name} × {qty}{?qty > 1} (bulk){/qty > 1}{?children} <i>{children}</i>{/children}</li>`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := goModule(t, map[string]string{
+		"views_gen.go": src,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func money(v any) string { return fmt.Sprintf("$%.2f", v) }
+
+func main() {
+	var p CartProps
+	err := json.Unmarshal([]byte(` + "`" + `{
+		"items": [{"name": "Tea <green>", "qty": 2, "gift": true, "note": "wrap & ship"}, {"name": "Cup", "qty": 1}],
+		"qty": 3, "total": 12.5, "promo": "<b title=\"x\">Sale</b>", "user": {"admin": true, "url": "/admin?u=a&b"}, "locked": true
+	}` + "`" + `), &p)
+	if err != nil {
+		panic(err)
+	}
+	RenderCart(os.Stdout, p)
+	fmt.Println()
+	RenderCart(os.Stdout, CartProps{Qty: 1, Title: "Basket"})
+}
+`,
+	})
+	out, err := goCommand(t, dir, "run", ".")
+	if err != nil {
+		t.Fatalf("%v\n%s\n%s", err, out, src)
+	}
+	expected := `<h1 class="title">Cart</h1><ul><li class="gift">Tea &lt;green&gt; × 2 (bulk) <i>wrap &amp; ship</i></li><li class="plain">Cup × 1</li></ul><p data-count="2">2 lines</p><p title="&lt;b title=&#34;x&#34;&gt;Sale&lt;/b&gt;"><b title="x">Sale</b></p><p>3 items · $12.50</p><a href="/admin?u=a&amp;b">admin</a><input disabled value="none">
+<h1 class="title">Basket</h1><ul></ul><p>Empty</p><p data-count="0">0 lines</p><p title=""></p><p>1 item · $%!f(&lt;nil&gt;)</p><input value="none">`
+	if got := strings.TrimSpace(string(out)); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
@@ -0,0 +1,329 @@
+package restache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pluralCategories are the CLDR plural categories accepted as attributes of
+// a translation.
+var pluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+var (
+	ErrMessageChild       = errors.New("translation body may only contain text and placeholders")
+	ErrMessagePlaceholder = errors.New("translation placeholder must be an identifier")
+	ErrPluralCount        = errors.New("plural translation requires a count parameter")
+	ErrPluralForm         = errors.New("plural forms few and many require a translator")
+)
+
+// isTranslateTag reports whether s, the content of a variable tag, is an
+// inline translation such as t "greeting" name=user.name.
+func isTranslateTag(s string) bool {
+	if len(s) < 3 || s[0] != 't' || !spaceTable[s[1]] {
+		return false
+	}
+	rest := strings.TrimLeft(s[2:], whitespace)
+	return rest != "" && (rest[0] == '"' || rest[0] == '\'')
+}
+
+// parseTranslateTag parses an inline translation into its key and
+// attributes. Each name=value argument becomes an attribute; values are
+// primary expressions, and the values of plural categories must be strings.
+func parseTranslateTag(s string) (string, []Attribute, error) {
+	p := &exprParser{lex: exprLexer{src: s}}
+	if err := p.next(); err != nil { // skip "t"
+		return "", nil, err
+	}
+	if err := p.next(); err != nil {
+		return "", nil, err
+	}
+	key, err := strconv.Unquote(jsToGoString(p.tok.val))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := p.next(); err != nil {
+		return "", nil, err
+	}
+	var attrs []Attribute
+	for p.tok.kind != tokEOF {
+		if p.tok.kind != tokIdent {
+			return "", nil, p.unexpected()
+		}
+		name := p.tok.val
+		if err := p.next(); err != nil {
+			return "", nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return "", nil, err
+		}
+		start := p.lex.pos - len(p.tok.val)
+		e, err := p.parsePrimary()
+		if err != nil {
+			return "", nil, err
+		}
+		end := p.lex.pos - len(p.tok.val) // start of the lookahead token
+		a := Attribute{Key: name, Val: strings.TrimSpace(s[start:end]), IsExpr: true, Expr: e}
+		if slices.Contains(pluralCategories, name) {
+			lit, ok := e.(*LiteralExpr)
+			if !ok || lit.Kind != StringLiteral {
+				return "", nil, errors.New("plural form " + name + " must be a string")
+			}
+			if a.Val, err = strconv.Unquote(jsToGoString(lit.Value)); err != nil {
+				return "", nil, err
+			}
+			a.IsExpr, a.Expr = false, nil
+		}
+		attrs = append(attrs, a)
+	}
+	return key, attrs, nil
+}
+
+// jsToGoString converts a JavaScript string literal to a Go one that
+// strconv.Unquote accepts.
+func jsToGoString(s string) string {
+	if len(s) >= 2 && s[0] == '\'' {
+		body := strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`)
+		return `"` + strings.ReplaceAll(body, `"`, `\"`) + `"`
+	}
+	return s
+}
+
+// Message is a translatable message found in a template.
+type Message struct {
+	Key          string            `json:"-"`
+	Text         string            `json:"message,omitempty"` // default message; may be empty
+	Plurals      map[string]string `json:"plurals,omitempty"` // plural category to message
+	Placeholders []string          `json:"placeholders,omitempty"`
+	Refs         []string          `json:"references,omitempty"` // "file:line" of every use
+}
+
+// checkDefault returns ErrPluralForm if m has plural forms that only a
+// translator can select, so its default message cannot be rendered.
+func (m *Message) checkDefault() error {
+	for _, cat := range []string{"few", "many"} {
+		if _, ok := m.Plurals[cat]; ok {
+			return ErrPluralForm
+		}
+	}
+	return nil
+}
+
+// message builds the message of the translation node n. Placeholders in the
+// body that are not bound by an attribute are read from the current scope.
+func (n *Node) message() (*Message, []Attribute, error) {
+	m := &Message{Key: n.Data}
+	var (
+		text   strings.Builder
+		params []Attribute
+	)
+	for _, a := range n.Attr {
+		if !a.IsExpr && slices.Contains(pluralCategories, a.Key) {
+			if m.Plurals == nil {
+				m.Plurals = make(map[string]string)
+			}
+			m.Plurals[a.Key] = a.Val
+			continue
+		}
+		params = append(params, a)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case TextNode:
+			text.WriteString(c.Data)
+		case VariableNode:
+			if !isIdent(c.Data) {
+				return nil, nil, ErrMessagePlaceholder
+			}
+			text.WriteString("{" + c.Data + "}")
+			if !slices.ContainsFunc(params, func(a Attribute) bool { return a.Key == c.Data }) {
+				params = append(params, Attribute{
+					Key:    c.Data,
+					Val:    c.Data,
+					IsExpr: true,
+					Expr:   &PathExpr{Parts: []string{c.Data}},
+				})
+			}
+		case CommentNode:
+		default:
+			return nil, nil, ErrMessageChild
+		}
+	}
+	m.Text = strings.TrimSpace(text.String())
+	if m.Plurals != nil {
+		if m.Plurals["other"] == "" && m.Text != "" {
+			m.Plurals["other"] = m.Text
+		}
+		if !slices.ContainsFunc(params, func(a Attribute) bool { return a.Key == "count" }) {
+			return nil, nil, ErrPluralCount
+		}
+	}
+	if m.Key == "" {
+		m.Key = m.Text
+	}
+	for _, a := range params {
+		m.Placeholders = append(m.Placeholders, a.Key)
+	}
+	return m, params, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentPart(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtractMessages returns the messages of every translation in the tree
+// rooted at n, in document order. If text is true, untranslated text nodes
+// are extracted too, keyed by their own content. References name file.
+func ExtractMessages(n *Node, file string, text bool) ([]Message, error) {
+	var (
+		out  []Message
+		walk func(*Node) error
+	)
+	walk = func(p *Node) error {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			ref := file + ":" + strconv.Itoa(c.Pos.Line)
+			switch c.Type {
+			case TranslateNode:
+				m, _, err := c.message()
+				if err != nil {
+					return &SyntaxError{Pos: c.Pos, Msg: err.Error()}
+				}
+				m.Refs = []string{ref}
+				out = append(out, *m)
+				continue // the body is the message
+			case TextNode:
+				if s := strings.TrimSpace(c.Data); text && s != "" && !c.isRawText() {
+					out = append(out, Message{Key: s, Text: s, Refs: []string{ref}})
+				}
+			}
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(n); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Catalog merges the messages of many templates by key.
+type Catalog map[string]*Message
+
+// Add merges messages into c. Messages sharing a key must agree on their
+// default text and plural forms.
+func (c Catalog) Add(messages ...Message) error {
+	for _, m := range messages {
+		prev, ok := c[m.Key]
+		if !ok {
+			m.Refs = slices.Clone(m.Refs)
+			c[m.Key] = &m
+			continue
+		}
+		if m.Text != "" && prev.Text != "" && m.Text != prev.Text ||
+			m.Plurals != nil && prev.Plurals != nil && !maps.Equal(m.Plurals, prev.Plurals) {
+			return fmt.Errorf("restache: conflicting messages for key %q at %s and %s",
+				m.Key, strings.Join(prev.Refs, ", "), strings.Join(m.Refs, ", "))
+		}
+		if prev.Text == "" {
+			prev.Text = m.Text
+		}
+		if prev.Plurals == nil {
+			prev.Plurals = m.Plurals
+		}
+		for _, p := range m.Placeholders {
+			if !slices.Contains(prev.Placeholders, p) {
+				prev.Placeholders = append(prev.Placeholders, p)
+			}
+		}
+		prev.Refs = append(prev.Refs, m.Refs...)
+	}
+	return nil
+}
+
+func (c Catalog) keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteJSON writes c as a JSON object mapping keys to messages.
+func (c Catalog) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]*Message(c))
+}
+
+// WritePO writes c as a gettext PO template. Keys become message contexts;
+// plural messages use their "one" and "other" forms as msgid and
+// msgid_plural. A message with any other plural form cannot be written, as
+// translators supply the forms of their language in PO files.
+func (c Catalog) WritePO(w io.Writer) error {
+	for _, k := range c.keys() {
+		for _, cat := range pluralCategories {
+			if _, ok := c[k].Plurals[cat]; ok && cat != "one" && cat != "other" {
+				return fmt.Errorf("restache: message %q has a %s form, which PO templates cannot hold", k, cat)
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString("msgid \"\"\nmsgstr \"\"\n\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	for _, k := range c.keys() {
+		m := c[k]
+		b.WriteByte('\n')
+		if len(m.Placeholders) > 0 {
+			b.WriteString("#. placeholders: " + strings.Join(m.Placeholders, ", ") + "\n")
+		}
+		for _, ref := range m.Refs {
+			b.WriteString("#: " + ref + "\n")
+		}
+		b.WriteString("msgctxt " + poQuote(k) + "\n")
+		if m.Plurals != nil {
+			one, other := m.Plurals["one"], m.Plurals["other"]
+			if one == "" {
+				one = other
+			}
+			b.WriteString("msgid " + poQuote(one) + "\n")
+			b.WriteString("msgid_plural " + poQuote(other) + "\n")
+			b.WriteString("msgstr[0] \"\"\nmsgstr[1] \"\"\n")
+			continue
+		}
+		text := m.Text
+		if text == "" {
+			text = k
+		}
+		b.WriteString("msgid " + poQuote(text) + "\nmsgstr \"\"\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func poQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// jsString returns s as a JavaScript string literal.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
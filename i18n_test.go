@@ -0,0 +1,163 @@
+package restache_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tetsuo/restache"
+)
+
+const i18nTemplate = `<div>
+  <h1>{t "title"}</h1>
+  <p><t key="greet" name="{user.name}">Hello, {name}!</t></p>
+  <span>{t 'cart' count=items.length one="{count} item" other="{count} items"}</span>
+  <t count="{n}" one="One file">{count} files</t>
+  Static text
+</div>`
+
+func TestRenderTranslator(t *testing.T) {
+	root := parseNode(t, i18nTemplate)
+
+	var sb strings.Builder
+	_, err := restache.RenderWithOptions(&sb, root, restache.RenderOptions{Translator: "$t", TranslateText: true})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+
+	want := `export default function ($0) {return <div>` +
+		`<h1>{$t("title")}</h1>` +
+		`<p>{$t("greet", { name: $0.user?.name }, "Hello, {name}!")}</p>` +
+		`<span>{$t("cart", { count: $0.items?.length }, { one: "{count} item", other: "{count} items" })}</span>` +
+		`{$t("{count} files", { count: $0.n }, { one: "One file", other: "{count} files" })}` +
+		`{$t("Static text")}</div>;}`
+	if got := sb.String(); got != want {
+		t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", want, got)
+	}
+}
+
+func TestExtractMessages(t *testing.T) {
+	root := parseNode(t, i18nTemplate)
+
+	messages, err := restache.ExtractMessages(root, "a.stache", true)
+	if err != nil {
+		t.Fatalf("ExtractMessages error: %v", err)
+	}
+	other := parseNode(t, `<t key="greet">Hello, {name}!</t>`)
+	more, err := restache.ExtractMessages(other, "b.stache", false)
+	if err != nil {
+		t.Fatalf("ExtractMessages error: %v", err)
+	}
+
+	cat := make(restache.Catalog)
+	if err := cat.Add(append(messages, more...)...); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := cat.WritePO(&sb); err != nil {
+		t.Fatalf("WritePO error: %v", err)
+	}
+	want := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: a.stache:6
+msgctxt "Static text"
+msgid "Static text"
+msgstr ""
+
+#. placeholders: count
+#: a.stache:4
+msgctxt "cart"
+msgid "{count} item"
+msgid_plural "{count} items"
+msgstr[0] ""
+msgstr[1] ""
+
+#. placeholders: name
+#: a.stache:3
+#: b.stache:1
+msgctxt "greet"
+msgid "Hello, {name}!"
+msgstr ""
+
+#: a.stache:2
+msgctxt "title"
+msgid "title"
+msgstr ""
+
+#. placeholders: count
+#: a.stache:5
+msgctxt "{count} files"
+msgid "One file"
+msgid_plural "{count} files"
+msgstr[0] ""
+msgstr[1] ""
+`
+	if got := sb.String(); got != want {
+		t.Errorf("WritePO mismatch:\nwant:\n%s\ngot:\n%s\n", want, got)
+	}
+
+	sb.Reset()
+	if err := cat.WriteJSON(&sb); err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	if got := sb.String(); !strings.Contains(got, `"greet": {
+    "message": "Hello, {name}!",
+    "placeholders": [
+      "name"
+    ],
+    "references": [
+      "a.stache:3",
+      "b.stache:1"
+    ]
+  }`) {
+		t.Errorf("unexpected JSON catalog:\n%s", got)
+	}
+
+	t.Run("conflict", func(t *testing.T) {
+		conflict := parseNode(t, `<t key="greet">Hi, {name}!</t>`)
+		messages, err := restache.ExtractMessages(conflict, "c.stache", false)
+		if err != nil {
+			t.Fatalf("ExtractMessages error: %v", err)
+		}
+		want := `restache: conflicting messages for key "greet" at a.stache:3, b.stache:1 and c.stache:1`
+		if err := cat.Add(messages...); err == nil || err.Error() != want {
+			t.Errorf("got error %v, want %q", err, want)
+		}
+	})
+
+	t.Run("po plural forms", func(t *testing.T) {
+		messages, err := restache.ExtractMessages(parseNode(t, `<p>{t "files" count=n one="{count} file" few="{count} files" other="{count} files"}</p>`), "d.stache", false)
+		if err != nil {
+			t.Fatalf("ExtractMessages error: %v", err)
+		}
+		cat := make(restache.Catalog)
+		if err := cat.Add(messages...); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+		if err := cat.WriteJSON(&sb); err != nil {
+			t.Errorf("WriteJSON error: %v", err)
+		}
+		want := `restache: message "files" has a few form, which PO templates cannot hold`
+		if err := cat.WritePO(&sb); err == nil || err.Error() != want {
+			t.Errorf("got error %v, want %q", err, want)
+		}
+	})
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{`<t key="x">Hi <b>there</b></t>`, "restache: 1:1: " + restache.ErrMessageChild.Error()},
+		{`<t key="x">Hi {user.name}</t>`, "restache: 1:1: " + restache.ErrMessagePlaceholder.Error()},
+		{`<p>{t "x" one="item"}</p>`, "restache: 1:4: " + restache.ErrPluralCount.Error()},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.ExtractMessages(parseNode(t, tc.data), "x.stache", false)
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package restache_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestCompileLit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"todo-list.stache": {Data: []byte("<ul>{#items}<todo-item text={text} done={done}></todo-item>{/items}</ul>")},
+		"todo-item.stache": {Data: []byte("<li><input type=checkbox checked={done}><span>{&text}</span></li>")},
+		"list.stache":      {Data: []byte("<p>{title}</p>")},
+	}
+	results, err := restache.CompileAll(fsys, []string{"*.stache"}, restache.WithTarget(restache.TargetLit), restache.WithDefineElements())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		file     string
+		expected string
+	}{
+		{
+			file: "todo-list.stache",
+			expected: "import { html, nothing, LitElement } from 'lit';\n" +
+				"import { repeat } from 'lit/directives/repeat.js';\n" +
+				"import TodoItem from './todo-item.stache';\n" +
+				"export default function TodoList($0) {return html`<ul>${repeat($0.items ?? [], $1 => $1.key, $1 => html`${TodoItem({ text: $1.text, done: $1.done })}`)}</ul>`;}\n" +
+				"customElements.define(\"todo-list\", class extends LitElement {static properties = { items: { attribute: false } };render() {return TodoList(this);}});",
+		},
+		{
+			file: "todo-item.stache",
+			expected: "import { html, nothing, LitElement } from 'lit';\n" +
+				"import { unsafeHTML } from 'lit/directives/unsafe-html.js';\n" +
+				"export default function TodoItem($0) {return html`<li><input type=\"checkbox\" .checked=${$0.done}><span>${unsafeHTML($0.text)}</span></li>`;}\n" +
+				"customElements.define(\"todo-item\", class extends LitElement {static properties = { done: { attribute: false }, text: { attribute: false } };render() {return TodoItem(this);}});",
+		},
+	} {
+		if got := results[tc.file]; got.Err != nil || got.Contents != tc.expected {
+			t.Errorf("%s mismatch (err %v):\nwant:\n%s\ngot:\n%s", tc.file, got.Err, tc.expected, got.Contents)
+		}
+	}
+	if got := results["list.stache"]; got.Err == nil {
+		t.Errorf("list.stache: expected an error for a custom element name without a dash")
+	}
+}
+
+func TestRunLit(t *testing.T) {
+	files := map[string]string{
+		"todo-list.stache": `{@props title="Todo" items=[]}
+<h1 class="title">{title}</h1>
+<ul>{#items}<todo-item text={text} done={done}>{note}</todo-item>{/items}</ul>
+{^items.length}<p>Nothing to do</p>{/items.length}
+<p>{t "left" count=items.length one="{count} item" other="{count} items"}</p>
+<button onClick={clear} disabled={busy}>Clear</button>
+<div>{&footer}</div>`,
+		"todo-item.stache": `<li class="{done ? 'done' : ''}"><input type=checkbox checked={done}> {text}{?children} <i>{children}</i>{/children}</li>`,
+		"globals.js":       "globalThis.customElements = { define() {} };\n",
+		"main.js": `import './globals.js';
+import { render } from 'lit';
+import TodoList from './todo-list.stache';
+console.log(render(TodoList({
+  items: [{ key: 1, text: "a < b", done: true, note: "now" }, { key: 2, text: "c", done: false }],
+  busy: true, footer: "<em>2</em>", clear() {},
+})));
+console.log(render(TodoList({ title: "Done" })));
+`,
+	}
+	const want = `<h1 class="title">Todo</h1><ul><li class="done"><input type="checkbox" checked> a &lt; b <i>now</i></li><li class=""><input type="checkbox"> c</li></ul>` +
+		`<p>2 items</p><button disabled>Clear</button><div><em>2</em></div>` + "\n" +
+		`<h1 class="title">Done</h1><ul></ul><p>Nothing to do</p><p>0 items</p><button>Clear</button><div></div>`
+	if got := runJS(t, files, restache.WithTarget(restache.TargetLit), restache.WithDefineElements()); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,295 @@
+package restache
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// nativeStyles is the object class names are looked up in by React Native
+// components. The script of the template imports or declares it.
+const nativeStyles = "styles"
+
+// styleExpr is the style prop of a React Native component: its classes
+// looked up in the styles object, followed by the style it was given.
+type styleExpr struct {
+	Classes []Expr
+	Style   Expr // or nil
+}
+
+func (*styleExpr) expr() {}
+
+// ToReactNative rewrites the elements of the component n, in place, into the
+// React Native components rendering them, as listed in nativeElements, and
+// returns the names of the components used, to be imported from
+// react-native. Elements without an equivalent are an error.
+//
+// Classes become lookups in a styles object the script of the template must
+// import or declare, onClick becomes onPress, and text inputs are configured
+// from their type. Text, and the variables next to it, is wrapped in Text
+// where it appears outside of one; variables holding children are not.
+func ToReactNative(n *Node) ([]string, error) {
+	t := &nativeTransform{}
+	if n.Type == ComponentNode {
+		if s := n.style(); s != nil {
+			return nil, &SyntaxError{Pos: s.Pos, Msg: "scoped styles are not supported in React Native"}
+		}
+		if s := n.script(); s != nil {
+			t.styles = slices.Contains(parseScript(s.Data).names, nativeStyles)
+		}
+	}
+	if err := t.node(n, false); err != nil {
+		return nil, err
+	}
+	slices.Sort(t.used)
+	return t.used, nil
+}
+
+type nativeTransform struct {
+	styles bool     // whether the script declares the styles object
+	used   []string // components used
+	scope  int      // depth of ranges
+	props  []Prop   // of the component being transformed
+}
+
+func (t *nativeTransform) use(name string) {
+	if !slices.Contains(t.used, name) {
+		t.used = append(t.used, name)
+	}
+}
+
+// children transforms the children of p, wrapping runs of text in Text
+// unless they are already inside one.
+func (t *nativeTransform) children(p *Node, inText bool) error {
+	for c := p.FirstChild; c != nil; c = c.NextSibling {
+		if err := t.node(c, inText); err != nil {
+			return err
+		}
+	}
+	if inText {
+		return nil
+	}
+	for c := p.FirstChild; c != nil; {
+		if !t.inline(c) {
+			c = c.NextSibling
+			continue
+		}
+		start, text := c, false
+		for ; c != nil && t.inline(c); c = c.NextSibling {
+			text = text || c.Type != TextNode || strings.TrimSpace(c.Data) != ""
+		}
+		if !text {
+			// space between components renders nothing
+			for x := start; x != c; {
+				next := x.NextSibling
+				p.RemoveChild(x)
+				x = next
+			}
+			continue
+		}
+		wrap := &Node{Type: ElementNode, Data: "Text", Pos: start.Pos}
+		p.InsertBefore(wrap, start)
+		for x := start; x != c; {
+			next := x.NextSibling
+			p.RemoveChild(x)
+			wrap.AppendChild(x)
+			x = next
+		}
+		t.use("Text")
+	}
+	return nil
+}
+
+// inline reports whether n renders text: a text node, a translation, or a
+// variable other than children and the props declared as nodes.
+func (t *nativeTransform) inline(n *Node) bool {
+	switch n.Type {
+	case TextNode, TranslateNode:
+		return true
+	case VariableNode:
+		e, _ := n.expr()
+		path, ok := e.(*PathExpr)
+		if !ok || len(path.Parts) != 1 || t.scope > 0 {
+			return true
+		}
+		name := path.Parts[0]
+		return name != "children" && !slices.ContainsFunc(t.props, func(p Prop) bool {
+			return p.Name == name && p.Type == "node"
+		})
+	}
+	return false
+}
+
+func (t *nativeTransform) node(n *Node, inText bool) error {
+	switch n.Type {
+	case ComponentNode:
+		props := t.props
+		t.props = n.Props
+		defer func() { t.props = props }()
+		return t.children(n, false)
+	case ElementNode:
+		switch {
+		case n.DataAtom == 0 && (n.Data == "" || n.Data == keyedFragment):
+			return t.children(n, inText)
+		case n.DataAtom == 0 && !n.CustomElement:
+			return t.children(n, false) // a component's children
+		}
+		name, err := t.element(n)
+		if err != nil {
+			return err
+		}
+		return t.children(n, name == "Text")
+	case WhenNode, UnlessNode:
+		return t.children(n, inText)
+	case RangeNode:
+		t.scope++
+		defer func() { t.scope-- }()
+		return t.children(n, inText)
+	case UnescapedNode:
+		return &SyntaxError{Pos: n.Pos, Msg: "raw HTML is not supported in React Native"}
+	}
+	return nil
+}
+
+// element rewrites the element n into its React Native component and
+// returns the component's name.
+func (t *nativeTransform) element(n *Node) (string, error) {
+	name, ok := nativeElements[n.DataAtom]
+	if !ok {
+		return "", &SyntaxError{Pos: n.Pos, Msg: "<" + n.TagName() + "> has no React Native equivalent"}
+	}
+	if n.DataAtom == atom.Textarea && n.FirstChild != nil {
+		return "", &SyntaxError{Pos: n.Pos, Msg: "<textarea> content is not supported in React Native; use value"}
+	}
+	var (
+		attrs   []Attribute
+		classes []Expr
+		style   *Attribute
+	)
+	for _, a := range n.Attr {
+		switch {
+		case a.KeyAtom == atom.Class:
+			if a.IsExpr {
+				e, err := a.expr()
+				if err != nil {
+					return "", err
+				}
+				classes = append(classes, e)
+				continue
+			}
+			for _, c := range strings.Fields(a.Val) {
+				classes = append(classes, &LiteralExpr{Kind: StringLiteral, Value: jsString(c)})
+			}
+			continue
+		case a.KeyAtom == atom.Style:
+			if !a.IsExpr {
+				return "", &SyntaxError{Pos: n.Pos, Msg: "style attribute must be an expression in React Native"}
+			}
+			style = &a
+			continue
+		case a.KeyAtom == atom.Onclick:
+			a = Attribute{Key: "onPress", Val: a.Val, IsExpr: a.IsExpr, Expr: a.Expr}
+		case name == "TextInput" && a.KeyAtom == atom.Type:
+			if a.IsExpr {
+				return "", &SyntaxError{Pos: n.Pos, Msg: "input type must be static in React Native"}
+			}
+			switch typ := strings.ToLower(a.Val); {
+			case typ == "" || typ == "text" || typ == "search":
+			case typ == "password":
+				attrs = append(attrs, nativeFlag("secureTextEntry", true))
+			case nativeKeyboardTypes[typ] != "":
+				attrs = append(attrs, Attribute{Key: "keyboardType", Val: nativeKeyboardTypes[typ]})
+			default:
+				return "", &SyntaxError{Pos: n.Pos, Msg: "<input type=" + strconv.Quote(a.Val) + "> has no React Native equivalent"}
+			}
+			continue
+		case name == "TextInput" && a.KeyAtom == atom.Disabled:
+			if !a.IsExpr {
+				attrs = append(attrs, nativeFlag("editable", false))
+				continue
+			}
+			e, err := a.expr()
+			if err != nil {
+				return "", err
+			}
+			a = Attribute{Key: "editable", IsExpr: true, Expr: &UnaryExpr{Op: "!", X: e}}
+		}
+		attrs = append(attrs, a)
+	}
+	if n.DataAtom == atom.Textarea {
+		attrs = append(attrs, nativeFlag("multiline", true))
+	}
+	if len(classes) > 0 {
+		if !t.styles {
+			return "", &SyntaxError{Pos: n.Pos, Msg: "class requires the script to import or declare " + nativeStyles}
+		}
+		e := &styleExpr{Classes: classes}
+		if style != nil {
+			var err error
+			if e.Style, err = style.expr(); err != nil {
+				return "", err
+			}
+		}
+		attrs = append(attrs, Attribute{Key: "style", IsExpr: true, Expr: e})
+	} else if style != nil {
+		attrs = append(attrs, *style)
+	}
+	n.Data, n.DataAtom, n.Attr = name, 0, attrs
+	t.use(name)
+	return name, nil
+}
+
+func nativeFlag(key string, v bool) Attribute {
+	return Attribute{Key: key, IsExpr: true, Expr: &LiteralExpr{Kind: BoolLiteral, Value: strconv.FormatBool(v)}}
+}
+
+// renderStyle prints the style prop e.
+func (r *renderer) renderStyle(e *styleExpr) error {
+	items := len(e.Classes)
+	if e.Style != nil {
+		items++
+	}
+	if items > 1 {
+		if err := r.print1('['); err != nil {
+			return err
+		}
+	}
+	for i, c := range e.Classes {
+		if i > 0 {
+			if err := r.print(", "); err != nil {
+				return err
+			}
+		}
+		if lit, ok := c.(*LiteralExpr); ok && lit.Kind == StringLiteral {
+			if name, err := strconv.Unquote(lit.Value); err == nil && isIdent(name) {
+				if err := r.print(nativeStyles + "." + name); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := r.print(nativeStyles + "["); err != nil {
+			return err
+		}
+		if err := r.renderExpr(c, 0); err != nil {
+			return err
+		}
+		if err := r.print1(']'); err != nil {
+			return err
+		}
+	}
+	if e.Style != nil {
+		if err := r.print(", "); err != nil {
+			return err
+		}
+		if err := r.renderExpr(e.Style, 2); err != nil {
+			return err
+		}
+	}
+	if items > 1 {
+		return r.print1(']')
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package restache_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestToReactNative(t *testing.T) {
+	const file = "testdata/render_native.txt"
+	for _, tc := range buildTestcases(t, file) {
+		t.Run(fmt.Sprintf("%s L%d", file, tc.line), func(t *testing.T) {
+			root := parseNode(t, tc.data)
+			components, err := restache.ToReactNative(root)
+			if err != nil {
+				t.Fatalf("ToReactNative error: %v", err)
+			}
+			var sb strings.Builder
+			if _, err := restache.Render(&sb, root); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := "import { " + strings.Join(components, ", ") + " } from 'react-native';\n" + sb.String()
+			imports, body, _ := strings.Cut(tc.expected, "\n")
+			want := imports + "\nexport default function ($0) {return " + body + ";}"
+
+			if got != want {
+				t.Errorf("Render mismatch at line %d:\nwant:\n%s\ngot:\n%s\n", tc.line, want, got)
+			}
+		})
+	}
+}
+
+func TestToReactNativeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		msg  string
+	}{
+		{`<div><table></table></div>`, "<table> has no React Native equivalent"},
+		{`<input type="checkbox">`, `<input type="checkbox"> has no React Native equivalent`},
+		{`<div class="card"></div>`, "class requires the script to import or declare styles"},
+		{`<div style="color: red"></div>`, "style attribute must be an expression in React Native"},
+		{`<div>{&html}</div>`, "raw HTML is not supported in React Native"},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.ToReactNative(parseNode(t, tc.data))
+			var serr *restache.SyntaxError
+			if !errors.As(err, &serr) || serr.Msg != tc.msg {
+				t.Errorf("got error %v, want %q", err, tc.msg)
+			}
+		})
+	}
+}
+
+func TestCompileReactNative(t *testing.T) {
+	fsys := fstest.MapFS{
+		"card.stache": {Data: []byte("<script>import styles from './card.styles';</script>\n" +
+			`<div class="card elevated" style={extra}><span class="card-title">{title}</span><p class={variant}>{body}</p><badge label={tag} /></div>`)},
+		"badge.stache": {Data: []byte(`<span class="badge">{label}</span>`)},
+	}
+	results, err := restache.CompileAll(fsys, []string{"card.stache"}, restache.WithReactNative())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "import * as React from 'react';\n" +
+		"import Badge from './badge.stache';\n" +
+		"import { Text, View } from 'react-native';\n" +
+		"import styles from './card.styles';\n" +
+		`export default function Card($0) {return <View style={ [styles.card, styles.elevated, $0.extra] }>` +
+		`<Text style={ styles["card-title"] }>{$0.title}</Text><Text style={ styles[$0.variant] }>{$0.body}</Text><Badge label={ $0.tag }></Badge></View>;}`
+	if got := results["card.stache"]; got.Err != nil || got.Contents != want {
+		t.Errorf("card.stache mismatch (err %v):\nwant:\n%s\ngot:\n%s", got.Err, want, got.Contents)
+	}
+}
+
+func TestRunReactNative(t *testing.T) {
+	files := map[string]string{
+		"card.stache": "<script>import styles from './card.styles';</script>\n" +
+			`<div class="card elevated" style={extra}><span class="card-title">{title}</span><p class={variant}>{body}</p>` +
+			`<button onClick={buy}>Buy</button><input type="email" value={email} disabled={busy}><badge label={tag} /></div>`,
+		"badge.stache":   `<span>#{label}</span>`,
+		"card.styles.js": `export default { card: { padding: 8 }, elevated: { elevation: 2 }, "card-title": { fontWeight: "bold" }, warn: { color: "red" } };`,
+		"main.js": `import { createElement, renderToString } from 'react';
+import Card from './card.stache';
+console.log(renderToString(createElement(Card, {
+  title: "Hi", body: "<b>", variant: "warn", extra: { margin: 1 }, email: "a@b", busy: true, tag: "new", buy() {},
+})));
+`,
+	}
+	const want = `<View style="padding:8;elevation:2;margin:1"><Text style="font-weight:bold">Hi</Text><Text style="color:red">&lt;b&gt;</Text>` +
+		`<Pressable><Text>Buy</Text></Pressable><TextInput keyboardType="email-address" value="a@b"></TextInput><Text>#new</Text></View>`
+	if got := runJS(t, files, restache.WithReactNative()); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
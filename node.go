@@ -2,6 +2,7 @@ package restache
 
 import (
 	"slices"
+	"strings"
 
 	"golang.org/x/net/html/atom"
 )
@@ -18,6 +19,9 @@ const (
 	RangeNode
 	WhenNode
 	UnlessNode
+	UnescapedNode
+	TranslateNode
+	ScriptNode
 )
 
 type Attribute struct {
@@ -25,6 +29,10 @@ type Attribute struct {
 	KeyAtom atom.Atom
 	Val     string
 	IsExpr  bool
+	Expr    Expr // parsed Val, if IsExpr
+
+	// Deprecated: Filters lists the filter chain of Expr; use Expr.
+	Filters []Filter
 }
 
 type PathComponent struct {
@@ -40,6 +48,22 @@ type Node struct {
 	Data     string
 	Attr     []Attribute
 	Path     []PathComponent
+	Expr     Expr   // parsed Data of variables and sections
+	Props    []Prop // declared props of a component; nil if undeclared
+	Pos      Position
+
+	// Deprecated: Filters lists the filter chain of Expr; use Expr.
+	Filters []Filter
+
+	// Memo lists the props compared by a memoized component, all of them if
+	// empty; it is nil if the component is not memoized. ForwardRef makes a
+	// component receive a ref, which its template reads as @ref.
+	Memo       []string
+	ForwardRef bool
+
+	// CustomElement marks an element whose tag is not known to HTML as a
+	// custom element rather than a component.
+	CustomElement bool
 }
 
 func (n *Node) TagName() string {
@@ -49,6 +73,23 @@ func (n *Node) TagName() string {
 	return n.Data
 }
 
+// expr returns the parsed expression of a variable or section, parsing Data
+// if the node was not built by the parser.
+func (n *Node) expr() (Expr, error) {
+	if n.Expr != nil {
+		return n.Expr, nil
+	}
+	return ParseExpr(n.Data)
+}
+
+// expr returns the parsed expression of the expression attribute a.
+func (a Attribute) expr() (Expr, error) {
+	if a.Expr != nil {
+		return a.Expr, nil
+	}
+	return ParseExpr(a.Val)
+}
+
 // InsertBefore inserts newChild as a child of n, immediately before oldChild
 // in the sequence of n's children. oldChild may be nil, in which case newChild
 // is appended to the end of n's children.
@@ -126,6 +167,76 @@ func (n *Node) RemoveChild(c *Node) {
 	c.NextSibling = nil
 }
 
+// body returns the child of the component n that it renders, skipping its
+// script, its scoped style and the named components declared in the same
+// file.
+func (n *Node) body() *Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != ComponentNode && c.Type != ScriptNode {
+			return c
+		}
+	}
+	return nil
+}
+
+// script returns the script of the top-level component n, or nil.
+func (n *Node) script() *Node {
+	return n.rawChild(atom.Script)
+}
+
+// style returns the scoped style of the top-level component n, or nil.
+func (n *Node) style() *Node {
+	return n.rawChild(atom.Style)
+}
+
+func (n *Node) rawChild(a atom.Atom) *Node {
+	for c := n.FirstChild; c != nil && c.Type == ScriptNode; c = c.NextSibling {
+		if c.DataAtom == a {
+			return c
+		}
+	}
+	return nil
+}
+
+// scriptNames returns the identifiers declared by the script of the file
+// that are in scope of the component n. The script runs in the default
+// component, or in the module if the file has none.
+func (n *Node) scriptNames() []string {
+	root := n
+	if n.Parent != nil {
+		root = n.Parent
+	}
+	s := root.script()
+	if s == nil || n != root && root.body() != nil {
+		return nil
+	}
+	return parseScript(s.Data).names
+}
+
+// isRawText reports whether n is the content of a script or style element.
+func (n *Node) isRawText() bool {
+	return n.Type == TextNode && n.Parent != nil &&
+		(n.Parent.DataAtom == atom.Script || n.Parent.DataAtom == atom.Style)
+}
+
+// trimEdgeSpace removes the characters in cutset from the start and end of
+// the body of a component, where they indent the template rather than
+// separate its content.
+func (n *Node) trimEdgeSpace(cutset string) {
+	for c := n.FirstChild; c != nil && c.Type == TextNode; c = n.FirstChild {
+		if c.Data = strings.TrimLeft(c.Data, cutset); c.Data != "" {
+			break
+		}
+		n.RemoveChild(c)
+	}
+	for c := n.LastChild; c != nil && c.Type == TextNode; c = n.LastChild {
+		if c.Data = strings.TrimRight(c.Data, cutset); c.Data != "" {
+			break
+		}
+		n.RemoveChild(c)
+	}
+}
+
 func (n *Node) wrapChildrenInFragment() {
 	first := n.FirstChild
 	if first == nil {
@@ -156,7 +267,7 @@ func (n *Node) wrapChildrenInFragment() {
 		Path: slices.Clone(n.Path),
 	}
 	if n.Type == RangeNode {
-		frag.Data = "React.Fragment"
+		frag.Data = keyedFragment
 		frag.Attr = []Attribute{{
 			Key:    "key",
 			Val:    "key",
@@ -185,15 +296,16 @@ func (n *Node) nameEquals(name []byte) bool {
 	return true
 }
 
-// extractUnknownElementTags returns the .Data of every ElementNode whose DataAtom == 0,
-// without duplicates, in depth-first (pre-order) order.
-func (n *Node) extractUnknownElementTags() []string {
+// extractUnknownElements returns the first ElementNode of every .Data whose
+// DataAtom == 0, other than custom elements, in depth-first (pre-order)
+// order.
+func (n *Node) extractUnknownElements() []*Node {
 	if n == nil {
 		return nil
 	}
 
 	seen := make(map[string]struct{}, 16) // seen .Data values
-	out := make([]string, 0, 8)
+	out := make([]*Node, 0, 8)
 
 	stack := []*Node{n.FirstChild}
 
@@ -203,10 +315,10 @@ func (n *Node) extractUnknownElementTags() []string {
 		stack = stack[:i]
 
 		for c != nil {
-			if c.Type == ElementNode && c.DataAtom == 0 {
+			if c.Type == ElementNode && c.DataAtom == 0 && !c.CustomElement {
 				if _, ok := seen[c.Data]; !ok {
 					seen[c.Data] = struct{}{}
-					out = append(out, c.Data)
+					out = append(out, c)
 				}
 			}
 			if nx := c.NextSibling; nx != nil {
@@ -232,7 +344,7 @@ func (n *Node) renameUnknownElementTags(rewrites map[string]string) {
 		c := stack.pop()
 
 		for c != nil {
-			if c.Type == ElementNode && c.DataAtom == 0 {
+			if c.Type == ElementNode && c.DataAtom == 0 && !c.CustomElement {
 				if newVal, ok := rewrites[c.Data]; ok {
 					c.Data = newVal
 				}
@@ -245,6 +357,16 @@ func (n *Node) renameUnknownElementTags(rewrites map[string]string) {
 	}
 }
 
+// containsType reports whether any descendant of n has type t.
+func (n *Node) containsType(t NodeType) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == t || c.containsType(t) {
+			return true
+		}
+	}
+	return false
+}
+
 // nodeStack is a stack of nodes.
 type nodeStack []*Node
 
@@ -275,6 +397,28 @@ func (s *nodeStack) popUntilAtom(a atom.Atom) bool {
 	return false
 }
 
+// preformatted returns the innermost element whose text is kept verbatim,
+// or nil if there is none.
+func (s *nodeStack) preformatted() *Node {
+	for i := len(*s) - 1; i >= 0; i-- {
+		switch n := (*s)[i]; n.DataAtom {
+		case atom.Pre, atom.Textarea, atom.Listing, atom.Code, atom.Script, atom.Style:
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *nodeStack) popUntilType(t NodeType) bool {
+	for i := len(*s) - 1; i >= 0; i-- {
+		if (*s)[i].Type == t {
+			*s = (*s)[:i]
+			return true
+		}
+	}
+	return false
+}
+
 func (s *nodeStack) popUntilName(name []byte) bool {
 	for i := len(*s) - 1; i >= 0; i-- {
 		n := (*s)[i]
@@ -290,9 +434,20 @@ func (s *nodeStack) popControl(name []byte) (*Node, bool) {
 	for len(*s) > 1 {
 		n := s.pop()
 		if (n.Type == RangeNode || n.Type == WhenNode || n.Type == UnlessNode) &&
-			n.nameEquals(name) {
+			n.closedBy(name) {
 			return n, true
 		}
 	}
 	return nil, false
 }
+
+// closedBy reports whether the end tag {/name} closes the section n. Besides
+// its full expression, a section can be closed by the path its expression
+// starts with, as in {?status == "done"}...{/status}, or by an empty name.
+func (n *Node) closedBy(name []byte) bool {
+	if len(name) == 0 || n.nameEquals(name) {
+		return true
+	}
+	path := leadingPath(n.Expr)
+	return path != nil && path.String() == string(name)
+}
@@ -4,21 +4,91 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path"
 	"slices"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html/atom"
 )
 
 func Parse(r io.Reader) (node *Node, err error) {
-	p := newParser(r)
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseOptions configures ParseWithOptions.
+type ParseOptions struct {
+	// Filters lists the filter and helper names that expressions may call,
+	// either with the pipe syntax or as functions. If nil, names are not
+	// checked.
+	Filters []string
+
+	// Whitespace selects how text is treated. A template may override it
+	// with a <!-- restache:whitespace mode --> comment.
+	Whitespace WhitespaceMode
+
+	// CustomElements lists the tags, or path.Match patterns of tags such as
+	// "sl-*", of custom elements. They are kept as elements instead of
+	// components, with their attribute names as written. A template may
+	// add to them with a <!-- restache:customElements tag ... --> comment,
+	// which applies to the tags after it.
+	CustomElements []string
+}
+
+// WhitespaceMode selects how whitespace in text is treated.
+type WhitespaceMode uint8
+
+const (
+	// WhitespaceCollapse follows JSX: whitespace at either end of a text
+	// is dropped if it contains a newline; other runs become one space.
+	WhitespaceCollapse WhitespaceMode = iota
+	// WhitespacePreserve keeps text as written.
+	WhitespacePreserve
+	// WhitespaceTrim drops whitespace at either end of every text and
+	// collapses the rest.
+	WhitespaceTrim
+)
+
+var whitespaceModes = []string{"collapse", "preserve", "trim"}
+
+func (m WhitespaceMode) String() string {
+	if int(m) < len(whitespaceModes) {
+		return whitespaceModes[m]
+	}
+	return "WhitespaceMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ParseWhitespaceMode returns the mode named s.
+func ParseWhitespaceMode(s string) (WhitespaceMode, bool) {
+	i := slices.Index(whitespaceModes, s)
+	return WhitespaceMode(i), i >= 0
+}
+
+func ParseWithOptions(r io.Reader, opts ParseOptions) (node *Node, err error) {
+	p := newParser(r, opts)
 	if err = p.parse(); err != nil {
 		return
 	}
 	node = p.doc
+	node.setFilters()
 	return
 }
 
+// A SyntaxError describes a malformed or rejected construct in a template.
+type SyntaxError struct {
+	Pos Position
+	Msg string
+	Err error `json:"-"` // the error reported at Pos, if any
+}
+
+func (e *SyntaxError) Error() string {
+	return "restache: " + e.Pos.String() + ": " + e.Msg
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
 type insertionMode func(*parser) bool
 
 type parser struct {
@@ -29,17 +99,234 @@ type parser struct {
 	tt   TokenType
 	path []PathComponent
 	sc   bool // indicates self closing token
+	err  error
+
+	filters  map[string]struct{} // known filter names; nil accepts any
+	ws       WhitespaceMode
+	custom   []string // patterns of custom element tags
+	trimNext bool     // trim leading whitespace of the next text
+
+	components []*Node // named components, in order
+	script     *Node   // script of the file
+	style      *Node   // scoped style of the file
 }
 
-func newParser(r io.Reader) *parser {
+func newParser(r io.Reader, opts ParseOptions) *parser {
 	p := &parser{
 		z:   NewTokenizer(r),
 		im:  initialIM,
 		doc: &Node{Type: ComponentNode},
+		ws:  opts.Whitespace,
+	}
+	for _, pattern := range opts.CustomElements {
+		p.addCustomElement(pattern)
+	}
+	if opts.Filters != nil {
+		p.filters = make(map[string]struct{}, len(opts.Filters))
+		for _, name := range opts.Filters {
+			p.filters[name] = struct{}{}
+		}
 	}
 	return p
 }
 
+// parseExpr parses s, checking called filters and helpers against the known
+// set. On failure it records a SyntaxError and returns nil.
+func (p *parser) parseExpr(s string, pos Position) Expr {
+	e, err := ParseExpr(s)
+	if err != nil {
+		p.err = &SyntaxError{Pos: pos, Msg: err.Error() + " in " + strconv.Quote(s)}
+		return nil
+	}
+	p.checkCalls(e, pos)
+	return e
+}
+
+// checkCalls records a SyntaxError if e calls an unknown filter or helper.
+func (p *parser) checkCalls(e Expr, pos Position) {
+	if p.filters == nil {
+		return
+	}
+	walkExpr(e, func(e Expr) {
+		if call, ok := e.(*CallExpr); ok && p.err == nil {
+			if _, known := p.filters[call.Func]; !known {
+				p.err = &SyntaxError{Pos: pos, Msg: "unknown filter " + strconv.Quote(call.Func)}
+			}
+		}
+	})
+}
+
+// trimPrev trims the trailing whitespace of the text preceding the current
+// token, removing the text if nothing is left.
+func (p *parser) trimPrev() {
+	parent := p.oe.top()
+	if c := parent.LastChild; c != nil && c.Type == TextNode {
+		if c.Data = strings.TrimRight(c.Data, whitespace); c.Data == "" {
+			parent.RemoveChild(c)
+		}
+	}
+}
+
+// pragma applies a <!-- restache:name arg --> comment.
+func (p *parser) pragma() {
+	name, arg := p.z.Pragma()
+	switch string(name) {
+	case "whitespace":
+		mode, ok := ParseWhitespaceMode(string(arg))
+		if !ok {
+			p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "unknown whitespace mode " + strconv.Quote(string(arg))}
+			return
+		}
+		p.ws = mode
+	case "memo":
+		c := p.component()
+		c.Memo = strings.Fields(string(arg))
+		for _, name := range c.Memo {
+			if !isIdent(name) {
+				p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "invalid prop name " + strconv.Quote(name)}
+				return
+			}
+		}
+	case "forwardRef":
+		p.component().ForwardRef = true
+	case "customElements":
+		for _, pattern := range strings.Fields(string(arg)) {
+			p.addCustomElement(pattern)
+		}
+	default:
+		p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "unknown pragma " + strconv.Quote(string(name))}
+	}
+}
+
+// addCustomElement adds the tag pattern of custom elements.
+func (p *parser) addCustomElement(pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "invalid custom element pattern " + strconv.Quote(pattern)}
+		return
+	}
+	p.custom = append(p.custom, pattern)
+}
+
+// isCustomElement reports whether tag names a custom element.
+func (p *parser) isCustomElement(tag string) bool {
+	return slices.ContainsFunc(p.custom, func(pattern string) bool {
+		ok, _ := path.Match(pattern, tag)
+		return ok
+	})
+}
+
+// component returns the innermost open component.
+func (p *parser) component() *Node {
+	for i := len(p.oe) - 1; i > 0; i-- {
+		if p.oe[i].Type == ComponentNode {
+			return p.oe[i]
+		}
+	}
+	return p.doc
+}
+
+// startComponent opens the named component declared by e, a top-level
+// <template> element whose name attribute is e.Attr[i].
+func (p *parser) startComponent(e *Node, i int) {
+	name := pascalize(e.Attr[i].Val)
+	switch {
+	case !isIdent(name):
+		p.err = &SyntaxError{Pos: e.Pos, Msg: "invalid component name " + strconv.Quote(e.Attr[i].Val)}
+		return
+	case slices.ContainsFunc(p.components, func(c *Node) bool { return c.Data == name }):
+		p.err = &SyntaxError{Pos: e.Pos, Msg: "duplicate component " + strconv.Quote(name)}
+		return
+	}
+	c := &Node{Type: ComponentNode, Data: name, Pos: e.Pos}
+	if p.sc {
+		p.sc = false
+		p.endComponent(c)
+		return
+	}
+	p.oe = append(p.oe, c)
+}
+
+// closeComponent closes the innermost open named component, unless a
+// <template> element is open inside it. It reports whether it did.
+func (p *parser) closeComponent() bool {
+	for i := len(p.oe) - 1; i > 0; i-- {
+		switch n := p.oe[i]; {
+		case n.Type == ElementNode && n.DataAtom == atom.Template:
+			return false
+		case n.Type == ComponentNode:
+			p.oe = p.oe[:i]
+			p.endComponent(n)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) endComponent(c *Node) {
+	c.trimEdgeSpace(whitespace)
+	c.wrapChildrenInFragment()
+	p.components = append(p.components, c)
+}
+
+// directive applies a {@name args} tag.
+func (p *parser) directive() {
+	name, args := p.z.Directive()
+	switch string(name) {
+	case "props":
+		c := p.oe.top()
+		if c.Type != ComponentNode || c.Props != nil || c.hasContent() {
+			p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "props must be declared at the start of a component"}
+			return
+		}
+		props, err := parseProps(string(args))
+		if err != nil {
+			p.err = &SyntaxError{Pos: p.z.Pos(), Msg: err.Error() + " in " + strconv.Quote(string(args))}
+			return
+		}
+		for _, prop := range props {
+			if _, ok := p.filters[prop.Name]; ok {
+				p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "prop " + prop.Name + " has the name of a filter"}
+				return
+			}
+		}
+		c.Props = props
+	default:
+		p.err = &SyntaxError{Pos: p.z.Pos(), Msg: "unknown directive " + strconv.Quote(string(name))}
+	}
+}
+
+// endScript closes n, the script or scoped style of the file, keeping its
+// source as Data.
+func (p *parser) endScript(n *Node) {
+	p.oe.pop()
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		b.WriteString(c.Data)
+		n.RemoveChild(c)
+	}
+	n.Data = b.String()
+}
+
+// translate turns the element e, a <t> tag, into a translation whose key is
+// taken from its key attribute.
+func (p *parser) translate(e *Node) {
+	e.Type = TranslateNode
+	e.DataAtom = 0
+	e.Data = ""
+	attrs := e.Attr[:0]
+	for _, a := range e.Attr {
+		if a.KeyAtom != 0 {
+			a.Key, a.KeyAtom = a.KeyAtom.String(), 0
+		}
+		if a.Key == "key" {
+			e.Data = a.Val
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	e.Attr = attrs
+}
+
 // initialIM is the first insertion mode used.
 // It switches to inBodyIM after adding the root document.
 func initialIM(p *parser) bool {
@@ -51,13 +338,32 @@ func initialIM(p *parser) bool {
 func inBodyIM(p *parser) bool {
 	switch p.tt {
 	case TextToken:
-		raw := collapse(p.z.Raw())
-		if raw == nil {
-			return true
+		raw := p.z.Raw()
+		trimmed := bytes.TrimLeft(raw, whitespace)
+		pos := p.z.Pos().advance(raw[:len(raw)-len(trimmed)])
+		if p.trimNext {
+			raw = trimmed
+		}
+		if pre := p.oe.preformatted(); pre != nil || p.ws == WhitespacePreserve {
+			// a newline right after the start tag is not content
+			if pre != nil && pre.FirstChild == nil && dropsLeadingNewline(pre.DataAtom) && len(raw) > 0 && raw[0] == '\n' {
+				raw = raw[1:]
+			}
+			if len(raw) == 0 {
+				return true
+			}
+		} else {
+			if p.ws == WhitespaceTrim {
+				raw = bytes.TrimRight(trimmed, whitespace)
+			}
+			if raw = collapse(raw); raw == nil {
+				return true
+			}
 		}
 		n := &Node{
 			Type: TextNode,
 			Data: string(raw),
+			Pos:  pos,
 		}
 		p.oe.top().AppendChild(n)
 		return true
@@ -69,6 +375,7 @@ func inBodyIM(p *parser) bool {
 			Type:     ElementNode,
 			DataAtom: atom.Lookup(name),
 			Path:     slices.Clone(p.path),
+			Pos:      p.z.Pos(),
 		}
 
 		if e.DataAtom == 0 {
@@ -79,9 +386,27 @@ func inBodyIM(p *parser) bool {
 				e.DataAtom = 0
 			}
 		}
+		e.CustomElement = e.DataAtom == 0 && p.isCustomElement(e.Data)
 
 		if hasAttr {
-			if _, found := nonSpecCamelAttrTags[e.DataAtom]; found {
+			if e.CustomElement {
+				for hasAttr {
+					key, val, isExpr, more := p.z.TagAttr()
+					x := Attribute{
+						KeyAtom: atom.Lookup(key),
+						Val:     string(val),
+						IsExpr:  isExpr,
+					}
+					if x.KeyAtom == 0 {
+						x.Key = string(key)
+					}
+					if isExpr {
+						x.Expr = p.parseExpr(x.Val, e.Pos)
+					}
+					e.Attr = append(e.Attr, x)
+					hasAttr = more
+				}
+			} else if _, found := nonSpecCamelAttrTags[e.DataAtom]; found {
 				searchPrefix := uint64(e.DataAtom) << 32
 				for hasAttr {
 					key, val, isExpr, more := p.z.TagAttr()
@@ -104,6 +429,9 @@ func inBodyIM(p *parser) bool {
 							x.Key = string(key)
 						}
 					}
+					if isExpr {
+						x.Expr = p.parseExpr(x.Val, e.Pos)
+					}
 					e.Attr = append(e.Attr, x)
 					hasAttr = more
 				}
@@ -122,12 +450,52 @@ func inBodyIM(p *parser) bool {
 							x.Key = string(key)
 						}
 					}
+					if isExpr {
+						x.Expr = p.parseExpr(x.Val, e.Pos)
+					}
 					e.Attr = append(e.Attr, x)
 					hasAttr = more
 				}
 			}
 		}
 
+		if e.DataAtom == 0 && e.Data == "t" {
+			p.translate(e)
+		}
+
+		if e.DataAtom == atom.Template && len(p.oe) == 1 {
+			if i := slices.IndexFunc(e.Attr, func(a Attribute) bool { return a.KeyAtom == atom.Name }); i >= 0 {
+				p.startComponent(e, i)
+				return true
+			}
+		}
+
+		if e.DataAtom == atom.Script && len(e.Attr) == 0 && !p.sc && len(p.oe) == 1 &&
+			p.script == nil && len(p.components) == 0 && !p.doc.hasContent() {
+			// the script of the file
+			p.script = &Node{Type: ScriptNode, DataAtom: atom.Script, Pos: e.Pos}
+			p.oe = append(p.oe, p.script)
+			return true
+		}
+
+		if e.DataAtom == atom.Style && len(e.Attr) == 1 && e.Attr[0].KeyAtom == atom.Scoped && !p.sc && len(p.oe) == 1 {
+			// the scoped style of the file
+			if p.style != nil {
+				p.err = &SyntaxError{Pos: e.Pos, Msg: "duplicate scoped style"}
+				return true
+			}
+			p.style = &Node{Type: ScriptNode, DataAtom: atom.Style, Pos: e.Pos}
+			p.oe = append(p.oe, p.style)
+			return true
+		}
+
+		if e.DataAtom == atom.Script || e.DataAtom == atom.Style {
+			if i := slices.IndexFunc(e.Attr, func(a Attribute) bool { return a.Key == interpolateAttr }); i >= 0 {
+				e.Attr = slices.Delete(e.Attr, i, i+1)
+				p.z.interpolate = true
+			}
+		}
+
 		p.oe.top().AppendChild(e)
 
 		// If it's self-closing tag, or void element, don't push onto the stack:
@@ -145,6 +513,17 @@ func inBodyIM(p *parser) bool {
 
 	case EndTagToken:
 		name, _ := p.z.TagName()
+		if string(name) == "t" {
+			p.oe.popUntilType(TranslateNode)
+			return true
+		}
+		if string(name) == "template" && p.closeComponent() {
+			return true
+		}
+		if top := p.oe.top(); top.Type == ScriptNode && string(name) == top.DataAtom.String() {
+			p.endScript(top)
+			return true
+		}
 		// pop stack until a matching element is found
 		a := atom.Lookup(name)
 		if a != 0 {
@@ -155,13 +534,38 @@ func inBodyIM(p *parser) bool {
 		return true
 
 	case VariableToken:
-		p.oe.top().AppendChild(
-			&Node{
-				Type: VariableNode,
-				Data: string(bytes.TrimSpace(p.z.Raw())),
-				Path: slices.Clone(p.path),
-			},
-		)
+		n := &Node{
+			Type: VariableNode,
+			Data: string(bytes.TrimSpace(p.z.Raw())),
+			Path: slices.Clone(p.path),
+			Pos:  p.z.Pos(),
+		}
+		if isTranslateTag(n.Data) {
+			key, attrs, err := parseTranslateTag(n.Data)
+			if err != nil {
+				p.err = &SyntaxError{Pos: n.Pos, Msg: err.Error() + " in " + strconv.Quote(n.Data)}
+				return true
+			}
+			for _, a := range attrs {
+				p.checkCalls(a.Expr, n.Pos)
+			}
+			n.Type, n.Data, n.Attr = TranslateNode, key, attrs
+			p.oe.top().AppendChild(n)
+			return true
+		}
+		n.Expr = p.parseExpr(n.Data, n.Pos)
+		p.oe.top().AppendChild(n)
+		return true
+
+	case UnescapedToken:
+		n := &Node{
+			Type: UnescapedNode,
+			Data: string(bytes.TrimSpace(p.z.Raw())),
+			Path: slices.Clone(p.path),
+			Pos:  p.z.Pos(),
+		}
+		n.Expr = p.parseExpr(n.Data, n.Pos)
+		p.oe.top().AppendChild(n)
 		return true
 
 	case WhenToken:
@@ -169,7 +573,9 @@ func inBodyIM(p *parser) bool {
 			Type: WhenNode,
 			Data: string(bytes.TrimSpace(p.z.ControlName())),
 			Path: slices.Clone(p.path),
+			Pos:  p.z.Pos(),
 		}
+		node.Expr = p.parseExpr(node.Data, node.Pos)
 		p.oe.top().AppendChild(node)
 		p.oe = append(p.oe, node)
 		return true
@@ -179,7 +585,9 @@ func inBodyIM(p *parser) bool {
 			Type: UnlessNode,
 			Data: string(bytes.TrimSpace(p.z.ControlName())),
 			Path: slices.Clone(p.path),
+			Pos:  p.z.Pos(),
 		}
+		node.Expr = p.parseExpr(node.Data, node.Pos)
 		p.oe.top().AppendChild(node)
 		p.oe = append(p.oe, node)
 		return true
@@ -189,8 +597,13 @@ func inBodyIM(p *parser) bool {
 			Type: RangeNode,
 			Data: string(bytes.TrimSpace(p.z.ControlName())),
 			Path: slices.Clone(p.path),
+			Pos:  p.z.Pos(),
+		}
+		node.Expr = p.parseExpr(node.Data, node.Pos)
+		parts := []string{node.Data}
+		if path, ok := node.Expr.(*PathExpr); ok {
+			parts = path.Parts
 		}
-		parts := strings.Split(node.Data, ".")
 		var (
 			i    int
 			part string
@@ -221,11 +634,20 @@ func inBodyIM(p *parser) bool {
 		}
 		return true
 
+	case PragmaToken:
+		p.pragma()
+		return true
+
+	case DirectiveToken:
+		p.directive()
+		return true
+
 	case CommentToken:
 		p.oe.top().AppendChild(
 			&Node{
 				Type: CommentNode,
 				Data: string(bytes.TrimSpace(p.z.Comment())),
+				Pos:  p.z.Pos(),
 			},
 		)
 		return true
@@ -242,10 +664,16 @@ func (p *parser) parseCurrentToken() {
 		p.tt = StartTagToken
 	}
 
+	before, after := p.z.Trim()
+	if before {
+		p.trimPrev()
+	}
+
 	consumed := false
 	for !consumed {
 		consumed = p.im(p)
 	}
+	p.trimNext = after
 }
 
 func (p *parser) parse() error {
@@ -259,13 +687,44 @@ func (p *parser) parse() error {
 			break
 		}
 		p.parseCurrentToken()
+		if p.err != nil {
+			return p.err
+		}
 	}
 	if p.doc.Type == ComponentNode {
-		p.doc.wrapChildrenInFragment()
+		if top := p.oe.top(); top != nil && top.Type == ScriptNode {
+			p.endScript(top)
+		}
+		p.closeComponent()
+		p.doc.trimEdgeSpace(whitespace)
+		if p.doc.FirstChild != nil || len(p.components) == 0 {
+			p.doc.wrapChildrenInFragment()
+		}
+		for _, c := range p.components {
+			p.doc.AppendChild(c)
+		}
+		if p.style != nil {
+			p.doc.InsertBefore(p.style, p.doc.FirstChild)
+		}
+		if p.script != nil {
+			p.doc.InsertBefore(p.script, p.doc.FirstChild)
+		}
 	}
 	return nil
 }
 
+const whitespace = " \t\n\f\r"
+
+// interpolateAttr marks a script or style element whose content may hold
+// tags, which is otherwise kept as raw text.
+const interpolateAttr = "restache:interpolate"
+
+// dropsLeadingNewline reports whether a newline right after the start tag of
+// an element a is not part of its content.
+func dropsLeadingNewline(a atom.Atom) bool {
+	return a == atom.Pre || a == atom.Listing || a == atom.Textarea
+}
+
 const hash0 = 0x84f70e16
 
 func fnv(h uint32, s []byte) (uint32, bool, int) {
@@ -307,31 +766,43 @@ func camelize(b []byte, offset int) []byte {
 	return b[:n]
 }
 
+// collapse applies JSX whitespace rules to text: whitespace at either end
+// is removed if it contains a newline, and every other run of whitespace
+// becomes a single space. It returns nil if nothing is left.
 func collapse(b []byte) []byte {
 	if len(b) == 0 {
 		return nil
 	}
 
 	w := 0
-	prevSpace := false
-	hasNonSpace := false
+	run := -1 // start of the current whitespace run in b, or -1
+	newline := false
 
-	for _, c := range b {
+	for i, c := range b {
 		if spaceTable[c] {
-			if !prevSpace {
+			if run < 0 {
+				run = i
+				newline = false
+			}
+			newline = newline || c == '\n'
+			continue
+		}
+		if run >= 0 {
+			if run > 0 || !newline {
 				b[w] = ' '
 				w++
-				prevSpace = true
 			}
-		} else {
-			b[w] = c
-			w++
-			prevSpace = false
-			hasNonSpace = true
+			run = -1
 		}
+		b[w] = c
+		w++
 	}
 
-	if !hasNonSpace {
+	if run >= 0 && !newline {
+		b[w] = ' '
+		w++
+	}
+	if w == 0 {
 		return nil
 	}
 	return b[:w]
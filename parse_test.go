@@ -50,6 +50,123 @@ func TestParse(t *testing.T) {
 	})
 }
 
+func TestParseExpr(t *testing.T) {
+	opts := restache.ParseOptions{Filters: []string{"currency", "date"}}
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{"{price | currency}", ""},
+		{"<p>\n  {createdAt | date 'short'}\n</p>", ""},
+		{`{?status == "done"}ok{/status}`, ""},
+		{`{a ? currency(b) : !c && d ?? "x"}`, ""},
+		{"<p>\n  {name | upper}</p>", `restache: 2:3: unknown filter "upper"`},
+		{`<a href="{url | slug}"></a>`, `restache: 1:1: unknown filter "slug"`},
+		{"<b>{fmt(x)}</b>", `restache: 1:4: unknown filter "fmt"`},
+		{"{| currency}", `restache: 1:1: unexpected | in "| currency"`},
+		{"x {price |}", `restache: 1:3: missing filter name after '|' in "price |"`},
+		{`{price | date "short}`, `restache: 1:1: unterminated string literal in "price | date \"short"`},
+		{"{a == }", `restache: 1:1: unexpected end of expression in "a =="`},
+		{"{ }", `restache: 1:1: empty expression in ""`},
+		{"{a.}", `restache: 1:1: unexpected end of expression in "a."`},
+		{"{a b}", `restache: 1:1: unexpected b in "a b"`},
+		{"{a + b}", `restache: 1:1: unexpected character in "a + b"`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.ParseWithOptions(strings.NewReader(tc.data), opts)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("got error %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("unchecked without options", func(t *testing.T) {
+		root, err := restache.Parse(strings.NewReader("{name | upper 2}"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		call, ok := root.FirstChild.Expr.(*restache.CallExpr)
+		if !ok || call.Func != "upper" || len(call.Args) != 2 {
+			t.Fatalf("unexpected expression: %#v", root.FirstChild.Expr)
+		}
+		if path, ok := call.Args[0].(*restache.PathExpr); !ok || path.String() != "name" {
+			t.Errorf("unexpected first argument: %#v", call.Args[0])
+		}
+		if lit, ok := call.Args[1].(*restache.LiteralExpr); !ok || lit.Kind != restache.NumberLiteral || lit.Value != "2" {
+			t.Errorf("unexpected second argument: %#v", call.Args[1])
+		}
+		if f := root.FirstChild.Filters; len(f) != 1 || f[0].Name != "upper" || len(f[0].Args) != 1 || f[0].Args[0] != "2" {
+			t.Errorf("unexpected filters: %+v", f)
+		}
+	})
+
+	t.Run("direct calls are not filters", func(t *testing.T) {
+		root, err := restache.Parse(strings.NewReader(`<p title={fmt(a, b)}>{fmt(a, b)}{fmt(a) | upper}</p>`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		p := root.FirstChild
+		if f := p.Attr[0].Filters; len(f) != 0 {
+			t.Errorf("unexpected attribute filters: %+v", f)
+		}
+		if f := p.FirstChild.Filters; len(f) != 0 {
+			t.Errorf("unexpected filters: %+v", f)
+		}
+		if f := p.LastChild.Filters; len(f) != 1 || f[0].Name != "upper" || len(f[0].Args) != 0 {
+			t.Errorf("unexpected filters of a pipe: %+v", f)
+		}
+	})
+}
+
+func TestParsePragma(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{"<!-- restache:whitespace trim -->", ""},
+		{"<!-- restache: whitespace -->", `restache: 1:1: unknown whitespace mode ""`},
+		{"<p>\n<!--restache:whitespace tidy--></p>", `restache: 2:1: unknown whitespace mode "tidy"`},
+		{"<!-- restache:strict -->", `restache: 1:1: unknown pragma "strict"`},
+		{"<!-- restache is great -->", ""},
+		{"<!-- restache:memo id title -->", ""},
+		{"<!-- restache:memo a.b -->", `restache: 1:1: invalid prop name "a.b"`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.Parse(strings.NewReader(tc.data))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("got error %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseComponentErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{"<template name=row></template>\n<template name=Row></template>", `restache: 2:1: duplicate component "Row"`},
+		{`<template name="2col"></template>`, `restache: 1:1: invalid component name "2col"`},
+		{`<template name=""></template>`, `restache: 1:1: invalid component name ""`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.Parse(strings.NewReader(tc.data))
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
 func TestNodePanic(t *testing.T) {
 	checkPanic := func(expected string, actual any) {
 		if msg, ok := actual.(string); ok {
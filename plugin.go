@@ -2,10 +2,13 @@ package restache
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,8 +25,18 @@ func Plugin(opts ...PluginOption) api.Plugin {
 	return api.Plugin{
 		Name: "stache-loader",
 		Setup: func(pb api.PluginBuild) {
-			p := &plugin{cfg: &cfg, buildOptions: pb.InitialOptions, resolveFunc: pb.Resolve}
-			pb.OnLoad(api.OnLoadOptions{Filter: regexp.QuoteMeta(cfg.extName) + "$"}, p.onLoad)
+			p := &plugin{
+				cfg:           &cfg,
+				buildOptions:  pb.InitialOptions,
+				resolveFunc:   pb.Resolve,
+				readFile:      os.ReadFile,
+				readDir:       os.ReadDir,
+				cache:         newCompileCache(cfg.cacheDir),
+				virtualStyles: true,
+			}
+			pb.OnLoad(api.OnLoadOptions{Filter: regexp.QuoteMeta(cfg.extName) + "$", Namespace: "file"}, p.onLoad)
+			pb.OnResolve(api.OnResolveOptions{Filter: "^" + styleNamespace + ":"}, p.onResolveStyle)
+			pb.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: styleNamespace}, p.onLoadStyle)
 		},
 	}
 }
@@ -32,6 +45,24 @@ type pluginConfig struct {
 	extName     string
 	tagPrefixes map[string]string
 	tagMappings map[string]string
+	filters     map[string]string
+	whitespace  WhitespaceMode
+	cacheDir    string
+	cssModules  bool
+	memo        bool
+	forwardRef  bool
+	jsxRuntime  *JSXRuntime
+	jsOutput    bool
+	target      Target
+	define      bool
+	native      bool
+	resolver    Resolver
+	custom      []string
+
+	strictAccess  bool
+	sanitizer     string
+	translator    string
+	translateText bool
 }
 
 type PluginOption func(*pluginConfig)
@@ -48,6 +79,149 @@ func WithTagMappings(tagMappings map[string]string) PluginOption {
 	}
 }
 
+// WithFilters maps filter names usable in pipe expressions to the JavaScript
+// modules that export them. A module may be suffixed with "#name" to import an
+// export whose name differs from the filter's; otherwise the export is
+// expected to have the filter's name. Templates using any other filter fail
+// to compile.
+func WithFilters(filters map[string]string) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.filters = filters
+	}
+}
+
+// WithWhitespace sets how whitespace in template text is treated. Templates
+// may still override it with a <!-- restache:whitespace mode --> comment.
+func WithWhitespace(mode WhitespaceMode) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.whitespace = mode
+	}
+}
+
+// WithCustomElements emits the tags matching patterns, names or path.Match
+// patterns such as "sl-*", as custom elements instead of importing them as
+// components. Their attributes keep the names they are written with, as
+// React passes them to custom elements, so class stays class. Templates may
+// list more with a <!-- restache:customElements tag ... --> comment.
+func WithCustomElements(patterns ...string) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.custom = append(cfg.custom, patterns...)
+	}
+}
+
+// WithStrictAccess turns off the null-safe property access used by default,
+// so that missing intermediate values throw instead of rendering nothing.
+func WithStrictAccess() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.strictAccess = true
+	}
+}
+
+// WithSanitizer routes unescaped HTML through a function exported by module
+// before it is inserted. The module's default export is used, unless module is
+// suffixed with "#name".
+func WithSanitizer(module string) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.sanitizer = module
+	}
+}
+
+// WithTranslator routes translations through a function exported by module,
+// typically a thin adapter over an i18n runtime. The module's default export
+// is used, unless module is suffixed with "#name". The function is called with
+// the message key, its parameters and its default message or plural forms.
+func WithTranslator(module string) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.translator = module
+	}
+}
+
+// WithTranslateText also routes untagged text through the translator, keyed by
+// the text itself. It has no effect without WithTranslator.
+func WithTranslateText() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.translateText = true
+	}
+}
+
+// WithCacheDir persists compiled templates to dir, so that later builds, not
+// only rebuilds of the same esbuild context, can skip unchanged templates.
+func WithCacheDir(dir string) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.cacheDir = dir
+	}
+}
+
+// WithMemo wraps every compiled component in React.memo. Templates may
+// instead opt in with a <!-- restache:memo --> comment, which can also list
+// the props to compare.
+func WithMemo() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.memo = true
+	}
+}
+
+// WithForwardRef wraps every compiled component in React.forwardRef, so that
+// templates can pass the ref on with ref={@ref}. Templates may instead opt in
+// with a <!-- restache:forwardRef --> comment.
+func WithForwardRef() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.forwardRef = true
+	}
+}
+
+// WithJSXRuntime compiles templates for rt instead of React's classic
+// runtime, such as ReactAutomaticRuntime or PreactRuntime.
+func WithJSXRuntime(rt JSXRuntime) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.jsxRuntime = &rt
+	}
+}
+
+// WithCreateElement compiles templates to plain JavaScript that calls the
+// element factory of the JSX runtime, so esbuild loads them without parsing
+// JSX.
+func WithCreateElement() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.jsOutput = true
+	}
+}
+
+// WithTarget compiles templates for target, such as TargetLit or TargetDOM,
+// instead of React.
+func WithTarget(target Target) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.target = target
+	}
+}
+
+// WithDefineElements, with TargetLit, also defines each template's component
+// as a custom element named after the template file, such as todo-item for
+// todo-item.stache.
+func WithDefineElements() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.define = true
+	}
+}
+
+// WithReactNative compiles templates for React Native, rendering their
+// elements with the components of react-native as described by
+// ToReactNative.
+func WithReactNative() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.native = true
+	}
+}
+
+// WithCSSModules scopes the classes of the stylesheet next to each template,
+// named after it with a .module.css extension, as if it were the template's
+// scoped style.
+func WithCSSModules() PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.cssModules = true
+	}
+}
+
 func WithExtensionName(extName string) PluginOption {
 	extName = sanitizeExtensionName(extName)
 	return func(cfg *pluginConfig) {
@@ -65,6 +239,12 @@ type plugin struct {
 	cfg          *pluginConfig
 	buildOptions *api.BuildOptions
 	resolveFunc  func(path string, options api.ResolveOptions) api.ResolveResult
+	readFile     func(name string) ([]byte, error)
+	readDir      func(name string) ([]fs.DirEntry, error)
+	cache        *compileCache
+
+	// virtualStyles imports the styles of a template from styleNamespace.
+	virtualStyles bool
 }
 
 func (p *plugin) resolvePath(path string, resolveDir string) (string, bool, error) {
@@ -100,85 +280,51 @@ var (
 	currentPath = "." + fileSep
 )
 
-func (p *plugin) buildImports(r *importResolver, root *Node, resolveDir string) (map[string]string, error) {
+func (p *plugin) buildImports(r *importResolver, root *Node, importer string) (map[string]string, error) {
 	rewrites := make(map[string]string) // orig tag to local ident
 
-	for _, tag := range root.extractUnknownElementTags() {
-		if tag == "React.Fragment" || tag == "" {
+	// components declared in the same file come first
+	var props []string
+	for _, c := range append([]*Node{root}, root.components()...) {
+		if c != root {
+			r.locals[c.Data] = struct{}{}
+		}
+		for _, prop := range c.Props {
+			props = append(props, prop.Name)
+		}
+	}
+
+	for _, n := range root.extractUnknownElements() {
+		tag := n.Data
+		if tag == keyedFragment || tag == "" {
 			continue
 		}
 
-		if path, ok := p.cfg.tagMappings[tag]; ok {
-			var (
-				resolved string
-				err      error
-			)
-			if !filepath.IsAbs(path) {
-				if resolved, _, err = p.resolvePath(path, resolveDir); err != nil {
-					return nil, err
-				}
-			} else {
-				resolved = path
-			}
-			rewrites[tag] = r.addImportByTag(tag, resolved)
+		if _, ok := r.locals[pascalize(tag)]; ok {
+			rewrites[tag] = pascalize(tag)
 		} else {
-			prefix, baseName := tagNameParts(tag)
-
-			// unique local id (ButtonGroup, ButtonGroup2, ...)
-			pascal := pascalize(baseName)
-			ident := r.nextID(pascal)
-
-			if prefix != "" {
-				if basePath, ok := p.cfg.tagPrefixes[prefix]; ok {
-					if !strings.HasSuffix(basePath, fileSep) {
-						basePath += fileSep
-					}
-					if resolved, _, err := p.resolvePathAny(
-						resolveDir,
-						[]string{basePath + pascal, basePath + baseName}...,
-					); err != nil {
-						return nil, err
-					} else {
-						rewrites[tag] = r.addImportByID(ident, resolved)
-					}
-				} else {
-					prefixedPascal := pascalize(prefix) + pascal
-					if resolved, _, err := p.resolvePathAny(
-						resolveDir, []string{
-							currentPath + sanitizeFileName(tag),
-							currentPath + prefixedPascal,
-							currentPath + filepath.Join(prefix, sanitizeFileName(baseName)),
-							currentPath + filepath.Join(prefix, pascal),
-						}...); err != nil {
-						return nil, err
-					} else {
-						ident = r.nextID(prefixedPascal)
-						rewrites[tag] = r.addImportByID(ident, resolved)
-					}
-				}
-			} else {
-				if resolved, _, err := p.resolvePathAny(
-					resolveDir, []string{
-						currentPath + sanitizeFileName(tag),
-						currentPath + pascal,
-					}...); err != nil {
-					return nil, err
-				} else {
-					rewrites[tag] = r.addImportByID(ident, resolved)
-				}
+			spec, err := p.resolveTag(tag, importer)
+			if err != nil {
+				return nil, &SyntaxError{Pos: n.Pos, Msg: err.Error(), Err: err}
 			}
+			// unique local id (ButtonGroup, ButtonGroup2, ...)
+			rewrites[tag] = r.addImport(r.nextID(p.componentIdent(tag)), spec)
+		}
+		if slices.Contains(props, rewrites[tag]) {
+			return nil, &SyntaxError{Pos: n.Pos, Msg: "prop " + rewrites[tag] + " hides the component of <" + tag + ">"}
 		}
 	}
 	return rewrites, nil
 }
 
-func (p *plugin) rewriteImports(root *Node, resolveDir string) error {
+func (p *plugin) rewriteImports(root *Node, importer string) error {
 	r := &importResolver{
-		importsByIDs: make(map[string]string),
-		idsByImports: make(map[string]string),
+		importsByIDs: make(map[string]ImportSpec),
+		idsByImports: make(map[ImportSpec]string),
+		locals:       make(map[string]struct{}),
 	}
 
-	rewrites, err := p.buildImports(r, root, resolveDir)
+	rewrites, err := p.buildImports(r, root, importer)
 	if err != nil {
 		return err
 	}
@@ -187,61 +333,341 @@ func (p *plugin) rewriteImports(root *Node, resolveDir string) error {
 
 	root.renameUnknownElementTags(rewrites)
 
+	resolveDir := filepath.Dir(importer)
+	if err := p.addFilterImports(root, resolveDir); err != nil {
+		return err
+	}
+	if err := p.addSanitizerImport(root, resolveDir); err != nil {
+		return err
+	}
+	return p.addTranslatorImport(root, resolveDir)
+}
+
+// resolveModule splits a "module#export" spec and resolves the module like a
+// tag mapping. export is empty if spec has no '#' suffix.
+func (p *plugin) resolveModule(spec, resolveDir string) (path, export string, err error) {
+	path = spec
+	if i := strings.LastIndexByte(spec, '#'); i != -1 {
+		path, export = spec[:i], spec[i+1:]
+	}
+	if !filepath.IsAbs(path) {
+		if path, _, err = p.resolvePath(path, resolveDir); err != nil {
+			return "", "", err
+		}
+	}
+	return path, export, nil
+}
+
+// addFilterImports appends a named import to root for every filter it uses.
+func (p *plugin) addFilterImports(root *Node, resolveDir string) error {
+	names := root.extractFilterNames()
+	sort.Strings(names)
+	for _, name := range names {
+		path, export, err := p.resolveModule(p.cfg.filters[name], resolveDir)
+		if err != nil {
+			return err
+		}
+		if export == "" {
+			export = name
+		}
+		clause := "{ " + name + " }"
+		if export != name {
+			clause = "{ " + export + " as " + name + " }"
+		}
+		root.Attr = append(root.Attr, Attribute{Key: clause, Val: path})
+	}
 	return nil
 }
 
 func (p *plugin) onLoad(args api.OnLoadArgs) (api.OnLoadResult, error) {
-	root, err := parseFile(args.Path)
+	data, err := os.ReadFile(args.Path)
 	if err != nil {
 		return api.OnLoadResult{}, err
 	}
 	resolveDir := filepath.Dir(args.Path)
 
-	componentName := strings.TrimSuffix(filepath.Base(args.Path), filepath.Ext(args.Path))
-	root.Data = pascalize(componentName)
+	key := p.cacheKey(args.Path, data)
+	if e := p.cache.get(args.Path, key); e != nil && p.resolvesAs(e.Resolutions, args.Path) {
+		return api.OnLoadResult{
+			Contents:   &e.Contents,
+			Loader:     p.loader(),
+			ResolveDir: resolveDir,
+			Warnings:   messages(args.Path, e.Warnings),
+			WatchFiles: p.watchFiles(args.Path),
+		}, nil
+	}
+
+	var rec []resolution
+	contents, styles, warnings, err := p.recording(&rec).compile(args.Path, data)
+	if err != nil {
+		var serr *SyntaxError
+		if errors.As(err, &serr) {
+			return api.OnLoadResult{Errors: messages(args.Path, []*SyntaxError{serr}), WatchFiles: p.watchFiles(args.Path)}, nil
+		}
+		return api.OnLoadResult{}, err
+	}
+
+	result := api.OnLoadResult{
+		Contents:   &contents,
+		Loader:     p.loader(),
+		ResolveDir: resolveDir,
+		Warnings:   messages(args.Path, warnings),
+		WatchFiles: p.watchFiles(args.Path),
+	}
+	entry := &cacheEntry{Key: key, Contents: contents, Styles: styles, Resolutions: rec, Warnings: warnings}
+	if err := p.cache.put(args.Path, entry); err != nil {
+		result.Warnings = append(result.Warnings, api.Message{Text: "restache: cannot write cache: " + err.Error()})
+	}
+	return result, nil
+}
+
+// loader returns the esbuild loader of compiled templates.
+func (p *plugin) loader() api.Loader {
+	if p.cfg.jsOutput || p.cfg.target != TargetReact {
+		return api.LoaderJS
+	}
+	return api.LoaderJSX
+}
+
+// onResolveStyle resolves the styles imported by a template to the
+// template's path in styleNamespace.
+func (p *plugin) onResolveStyle(args api.OnResolveArgs) (api.OnResolveResult, error) {
+	name := strings.TrimPrefix(args.Path, styleNamespace+":")
+	return api.OnResolveResult{Path: filepath.Join(args.ResolveDir, name), Namespace: styleNamespace}, nil
+}
+
+// onLoadStyle loads the styles of the template last compiled at args.Path.
+func (p *plugin) onLoadStyle(args api.OnLoadArgs) (api.OnLoadResult, error) {
+	styles, ok := p.cache.styles(args.Path)
+	if !ok {
+		return api.OnLoadResult{}, fmt.Errorf("no styles compiled for %s", args.Path)
+	}
+	return api.OnLoadResult{
+		Contents:   &styles,
+		Loader:     api.LoaderCSS,
+		ResolveDir: filepath.Dir(args.Path),
+	}, nil
+}
+
+// messages converts errors found in file to esbuild messages.
+func messages(file string, errs []*SyntaxError) []api.Message {
+	var msgs []api.Message
+	for _, err := range errs {
+		msgs = append(msgs, api.Message{
+			Text: err.Msg,
+			Location: &api.Location{
+				File:   file,
+				Line:   err.Pos.Line,
+				Column: err.Pos.Col - 1,
+			},
+		})
+	}
+	return msgs
+}
+
+// compile compiles the template at path, with contents data, to a JSX
+// module and the stylesheet it imports, if any. Uses of undeclared props are
+// returned as warnings.
+func (p *plugin) compile(path string, data []byte) (string, string, []*SyntaxError, error) {
+	root, err := ParseWithOptions(bytes.NewReader(data), p.parseOptions())
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	componentName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	root.Data = ComponentName(path)
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == ComponentNode && c.Data == root.Data && root.body() != nil {
+			return "", "", nil, &SyntaxError{Pos: c.Pos, Msg: "component " + strconv.Quote(c.Data) + " has the name of the file"}
+		}
+	}
+
+	styles, classes, err := p.styles(path, root)
+	if err != nil {
+		return "", "", nil, err
+	}
 
 	var buf bytes.Buffer
 
+	opts := p.renderOptions()
+	opts.Classes = classes
+	if p.cfg.target == TargetLit && p.cfg.define && root.body() != nil {
+		opts.CustomElement = kebabize(componentName)
+		if !strings.Contains(opts.CustomElement, "-") {
+			return "", "", nil, fmt.Errorf("custom element name %q must contain a dash", opts.CustomElement)
+		}
+	}
+
 	if root.FirstChild != nil {
-		if err := p.rewriteImports(root, resolveDir); err != nil {
-			return api.OnLoadResult{}, err
+		if err := p.rewriteImports(root, path); err != nil {
+			return "", "", nil, err
+		}
+		if p.cfg.native {
+			components, err := ToReactNative(root)
+			if err != nil {
+				return "", "", nil, err
+			}
+			if len(components) > 0 {
+				root.Attr = append(root.Attr, Attribute{Key: "{ " + strings.Join(components, ", ") + " }", Val: "react-native"})
+			}
 		}
-		if _, err := buf.WriteString("import * as React from 'react';\n"); err != nil {
-			return api.OnLoadResult{}, err
+		if styles != "" && p.virtualStyles {
+			root.Attr = append(root.Attr, Attribute{Val: styleNamespace + ":" + filepath.Base(path)})
 		}
+		header := "import * as React from 'react';\n"
+		switch {
+		case p.cfg.target == TargetLit:
+			header = litHeader(root, opts.CustomElement != "")
+		case p.cfg.target == TargetDOM:
+			header = DOMRuntime
+		case p.cfg.jsxRuntime != nil:
+			if header, err = p.cfg.jsxRuntime.header(root, &opts); err != nil {
+				return "", "", nil, err
+			}
+		}
+		if _, err := buf.WriteString(header); err != nil {
+			return "", "", nil, err
+		}
+	}
+	if _, err := RenderWithOptions(&buf, root, opts); err != nil {
+		return "", "", nil, err
 	}
+	return buf.String(), styles, UndeclaredProps(root), nil
+}
 
-	if _, err := Render(&buf, root); err != nil {
-		return api.OnLoadResult{}, err
+// styles returns the stylesheet of the template at path, made of its CSS
+// module and its scoped style, with their classes renamed, and the new names
+// of the classes.
+func (p *plugin) styles(path string, root *Node) (string, map[string]string, error) {
+	var sources []string
+	module, err := p.cssModule(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if module != nil {
+		sources = append(sources, string(module))
+	}
+	if s := root.style(); s != nil {
+		sources = append(sources, s.Data)
+	}
+	if len(sources) == 0 {
+		return "", nil, nil
+	}
+	suffix := classSuffix(filepath.Base(path), strings.Join(sources, "\n"))
+	classes := make(map[string]string)
+	for i, css := range sources {
+		sources[i] = scopeClasses(css, suffix, classes)
+	}
+	return strings.Join(sources, "\n"), classes, nil
+}
+
+// cssModule returns the contents of the CSS module of the template at path,
+// or nil if there is none or CSS modules are off.
+func (p *plugin) cssModule(path string) ([]byte, error) {
+	if !p.cfg.cssModules {
+		return nil, nil
+	}
+	data, err := p.readFile(cssModulePath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
 	}
-	contents := buf.String()
-	// fmt.Println(contents)
+	return data, err
+}
 
-	return api.OnLoadResult{
-		Contents:   &contents,
-		Loader:     api.LoaderJSX,
-		ResolveDir: resolveDir,
-	}, nil
+func cssModulePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".module.css"
 }
 
-type importResolver struct {
-	importsByIDs map[string]string // local ident  to import path
-	idsByImports map[string]string // import path to local ident
+// watchFiles returns the files besides the template at path whose changes
+// affect its output, so esbuild reloads it in watch mode. The CSS module is
+// watched even before it exists.
+func (p *plugin) watchFiles(path string) []string {
+	if !p.cfg.cssModules {
+		return nil
+	}
+	return []string{cssModulePath(path)}
 }
 
-func (r *importResolver) existsByID(id string) bool {
-	return r.importsByIDs[id] != ""
+// Local names of the configured runtime imports.
+const (
+	sanitizerIdent  = "$sanitize"
+	translatorIdent = "$t"
+)
+
+// addTranslatorImport imports the configured translator if root has
+// translations, or text when untagged text is translated.
+func (p *plugin) addTranslatorImport(root *Node, resolveDir string) error {
+	if p.cfg.translator == "" ||
+		!root.containsType(TranslateNode) && !(p.cfg.translateText && root.containsType(TextNode)) {
+		return nil
+	}
+	return p.addRuntimeImport(root, p.cfg.translator, translatorIdent, resolveDir)
+}
+
+// addSanitizerImport imports the configured sanitizer if root inserts
+// unescaped HTML.
+func (p *plugin) addSanitizerImport(root *Node, resolveDir string) error {
+	if p.cfg.sanitizer == "" || !root.containsType(UnescapedNode) {
+		return nil
+	}
+	return p.addRuntimeImport(root, p.cfg.sanitizer, sanitizerIdent, resolveDir)
+}
+
+// addRuntimeImport imports the default or named export of the module spec
+// as ident.
+func (p *plugin) addRuntimeImport(root *Node, spec, ident, resolveDir string) error {
+	path, export, err := p.resolveModule(spec, resolveDir)
+	if err != nil {
+		return err
+	}
+	clause := ident
+	if export != "" {
+		clause = "{ " + export + " as " + ident + " }"
+	}
+	root.Attr = append(root.Attr, Attribute{Key: clause, Val: path})
+	return nil
 }
 
-func (r *importResolver) addImportByTag(tag, path string) string {
-	if e, ok := r.idsByImports[path]; ok {
-		return e
-	} else {
-		id := pascalize(tag)
-		r.importsByIDs[id] = path
-		r.idsByImports[path] = id
-		return id
+func (p *plugin) parseOptions() ParseOptions {
+	filters := make([]string, 0, len(p.cfg.filters))
+	for name := range p.cfg.filters {
+		filters = append(filters, name)
 	}
+	return ParseOptions{Filters: filters, Whitespace: p.cfg.whitespace, CustomElements: p.cfg.custom}
+}
+
+func (p *plugin) renderOptions() RenderOptions {
+	opts := RenderOptions{
+		Target:       p.cfg.target,
+		StrictAccess: p.cfg.strictAccess,
+		Memo:         p.cfg.memo,
+		ForwardRef:   p.cfg.forwardRef,
+	}
+	if p.cfg.jsOutput && p.cfg.target == TargetReact {
+		opts.CreateElement = "React.createElement"
+	}
+	if p.cfg.sanitizer != "" {
+		opts.Sanitizer = sanitizerIdent
+	}
+	if p.cfg.translator != "" {
+		opts.Translator = translatorIdent
+		opts.TranslateText = p.cfg.translateText
+	}
+	return opts
+}
+
+type importResolver struct {
+	importsByIDs map[string]ImportSpec // local ident to import
+	idsByImports map[ImportSpec]string // import to local ident
+	locals       map[string]struct{}   // components declared in the template
+}
+
+func (r *importResolver) existsByID(id string) bool {
+	if _, ok := r.locals[id]; ok {
+		return true
+	}
+	_, ok := r.importsByIDs[id]
+	return ok
 }
 
 func (r *importResolver) nextID(pascal string) string {
@@ -255,14 +681,15 @@ func (r *importResolver) nextID(pascal string) string {
 	return ident
 }
 
-func (r *importResolver) addImportByID(id, path string) string {
-	if existingIdent, ok := r.idsByImports[path]; ok {
+// addImport imports spec as id, unless it is already imported, and returns
+// its local ident.
+func (r *importResolver) addImport(id string, spec ImportSpec) string {
+	if existingIdent, ok := r.idsByImports[spec]; ok {
 		return existingIdent
-	} else {
-		r.importsByIDs[id] = path
-		r.idsByImports[path] = id
-		return id
 	}
+	r.importsByIDs[id] = spec
+	r.idsByImports[spec] = id
+	return id
 }
 
 func (r *importResolver) copyAttrs(targ *Node) {
@@ -272,21 +699,23 @@ func (r *importResolver) copyAttrs(targ *Node) {
 	}
 	sort.Strings(keys)
 	for _, local := range keys {
-		targ.Attr = append(targ.Attr, Attribute{Key: local, Val: r.importsByIDs[local]})
+		spec := r.importsByIDs[local]
+		clause := local
+		switch spec.Export {
+		case "":
+		case local:
+			clause = "{ " + local + " }"
+		default:
+			clause = "{ " + spec.Export + " as " + local + " }"
+		}
+		targ.Attr = append(targ.Attr, Attribute{Key: clause, Val: spec.Path})
 	}
 }
 
-func parseFile(path string) (*Node, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	node, err := Parse(f)
-	if err != nil {
-		return nil, err
-	}
-	return node, nil
+// ComponentName returns the name of the component defined by the template
+// at path: its file name without extension, in PascalCase.
+func ComponentName(path string) string {
+	return pascalize(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
 }
 
 func pascalize(s string) string {
@@ -309,6 +738,22 @@ func pascalize(s string) string {
 	return string(result)
 }
 
+// kebabize returns s in lower case with a dash before each word.
+func kebabize(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			if i > 0 && s[i-1] != '-' {
+				b.WriteByte('-')
+			}
+			c = c - 'A' + 'a'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 func sanitizeFileName(tagName string) string {
 	result := make([]byte, len(tagName))
 	for i := range len(tagName) {
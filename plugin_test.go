@@ -0,0 +1,117 @@
+package restache_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/tetsuo/restache"
+)
+
+func build(t *testing.T, entry string, opts ...restache.PluginOption) string {
+	t.Helper()
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Plugins:     []api.Plugin{restache.Plugin(opts...)},
+		Format:      api.FormatESModule,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors)
+	}
+	if len(result.Warnings) > 0 {
+		t.Fatalf("build warnings: %v", result.Warnings)
+	}
+	return string(result.OutputFiles[0].Contents)
+}
+
+// runJS writes files to a directory, bundles its main.js with the
+// stand-ins of testdata/eval for the packages templates import, and returns
+// what running it with node prints.
+func runJS(t *testing.T, files map[string]string, opts ...restache.PluginOption) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("runs node")
+	}
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip(err)
+	}
+	stubs, err := filepath.Abs("testdata/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, data := range files {
+		writeFile(t, filepath.Join(dir, name), data)
+	}
+	result := api.Build(api.BuildOptions{
+		EntryPoints:       []string{filepath.Join(dir, "main.js")},
+		Bundle:            true,
+		Platform:          api.PlatformNode,
+		Format:            api.FormatESModule,
+		NodePaths:         []string{stubs},
+		ResolveExtensions: []string{".stache", ".js"},
+		Plugins:           []api.Plugin{restache.Plugin(opts...)},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors)
+	}
+	cmd := exec.Command(node, "--input-type=module")
+	cmd.Stdin = bytes.NewReader(result.OutputFiles[0].Contents)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s\n%s", err, out, result.OutputFiles[0].Contents)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginCache(t *testing.T) {
+	src, cacheDir := t.TempDir(), t.TempDir()
+	entry := filepath.Join(src, "page.stache")
+	writeFile(t, entry, "<my-card></my-card>")
+	writeFile(t, filepath.Join(src, "MyCard.jsx"), "export default () => null;")
+
+	if out := build(t, entry, restache.WithCacheDir(cacheDir)); !strings.Contains(out, "MyCard.jsx") {
+		t.Fatalf("expected MyCard.jsx import, got:\n%s", out)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one cache entry, got %v", files)
+	}
+
+	// A cold build reads the persisted entry instead of compiling.
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := strings.Index(string(data), `"contents":"`) + len(`"contents":"`)
+	j := i + strings.Index(string(data[i:]), `","resolutions"`)
+	writeFile(t, files[0], string(data[:i])+"export default 42;"+string(data[j:]))
+
+	if out := build(t, entry, restache.WithCacheDir(cacheDir)); !strings.Contains(out, "= 42;") {
+		t.Fatalf("expected cached contents, got:\n%s", out)
+	}
+
+	// Other options do not share entries.
+	if out := build(t, entry, restache.WithCacheDir(cacheDir), restache.WithStrictAccess()); !strings.Contains(out, "MyCard.jsx") {
+		t.Fatalf("expected a fresh compile, got:\n%s", out)
+	}
+
+	// A file shadowing the resolved import invalidates the entry.
+	writeFile(t, filepath.Join(src, "my-card.jsx"), "export default () => null;")
+	if out := build(t, entry, restache.WithCacheDir(cacheDir)); !strings.Contains(out, "my-card.jsx") {
+		t.Fatalf("expected my-card.jsx import, got:\n%s", out)
+	}
+}
@@ -0,0 +1,193 @@
+package restache
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Prop is a property declared in the header of a component:
+//
+//	{@props items=[] title="Untitled" compact:boolean}
+type Prop struct {
+	Name    string
+	Type    string // one of propTypes, or "" if not declared
+	Default Expr   // a *LiteralExpr, or nil if there is none
+}
+
+// propTypes are the types a prop may be declared with.
+var propTypes = []string{"any", "array", "boolean", "function", "node", "number", "object", "string"}
+
+// jsReserved are the words that cannot name a binding in a module.
+var jsReserved = []string{
+	"arguments", "await", "break", "case", "catch", "class", "const", "continue",
+	"debugger", "default", "delete", "do", "else", "enum", "eval", "export",
+	"extends", "false", "finally", "for", "function", "if", "implements",
+	"import", "in", "instanceof", "interface", "let", "new", "null", "package",
+	"private", "protected", "public", "return", "static", "super", "switch",
+	"this", "throw", "true", "try", "typeof", "var", "void", "while", "with",
+	"yield",
+}
+
+// parseProps parses the arguments of a props header:
+//
+//	props = { ident [ ":" ident ] [ "=" literal ] }
+func parseProps(s string) ([]Prop, error) {
+	p := &exprParser{lex: exprLexer{src: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	props := []Prop{}
+	for p.tok.kind != tokEOF {
+		if p.tok.kind != tokIdent {
+			return nil, p.unexpected()
+		}
+		prop := Prop{Name: p.tok.val}
+		switch {
+		case strings.HasPrefix(prop.Name, "$") || prop.Name == "React" || slices.Contains(jsReserved, prop.Name):
+			return nil, errors.New("reserved prop name " + prop.Name)
+		case slices.ContainsFunc(props, func(q Prop) bool { return q.Name == prop.Name }):
+			return nil, errors.New("duplicate prop " + prop.Name)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.is(":") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent || !slices.Contains(propTypes, p.tok.val) {
+				return nil, errors.New("unknown type " + strconv.Quote(p.tok.val) + " of prop " + prop.Name)
+			}
+			prop.Type = p.tok.val
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			e, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := e.(*LiteralExpr); !ok {
+				return nil, errors.New("default of prop " + prop.Name + " must be a literal")
+			}
+			prop.Default = e
+		}
+		props = append(props, prop)
+	}
+	return props, nil
+}
+
+// hasContent reports whether n has children other than comments and
+// whitespace.
+func (n *Node) hasContent() bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != CommentNode && (c.Type != TextNode || strings.TrimSpace(c.Data) != "") {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) declares(name string) bool {
+	return slices.ContainsFunc(n.Props, func(p Prop) bool { return p.Name == name })
+}
+
+// UndeclaredProps checks the component n and the components declared in its
+// file against their props headers. It returns an error at the first use of
+// every variable a component reads from its props but does not declare.
+// Components without a header are not checked, nor are variables read from
+// the items of a range.
+func UndeclaredProps(n *Node) []*SyntaxError {
+	var errs []*SyntaxError
+	check := func(comp *Node) {
+		if comp.Props == nil {
+			return
+		}
+		seen := make(map[string]bool)
+		for _, name := range comp.scriptNames() {
+			seen[name] = true
+		}
+		comp.reads(func(pos Position, name string) {
+			if !comp.declares(name) && !seen[name] {
+				seen[name] = true
+				errs = append(errs, &SyntaxError{Pos: pos, Msg: "undeclared prop " + strconv.Quote(name)})
+			}
+		})
+	}
+	check(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == ComponentNode {
+			check(c)
+		}
+	}
+	return errs
+}
+
+// propNames returns the props of the component n: those it declares, or
+// else the variables it reads from its props, in order of first use.
+func (n *Node) propNames() []string {
+	if n.Props != nil {
+		names := make([]string, len(n.Props))
+		for i, p := range n.Props {
+			names[i] = p.Name
+		}
+		return names
+	}
+	script := n.scriptNames()
+	var names []string
+	n.reads(func(_ Position, name string) {
+		if !slices.Contains(names, name) && !slices.Contains(script, name) {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+// reads calls fn with every variable the component n reads from its props,
+// and the position of the node reading it.
+func (n *Node) reads(fn func(pos Position, name string)) {
+	use := func(pos Position, e Expr) {
+		walkExpr(e, func(e Expr) {
+			if path, ok := e.(*PathExpr); ok {
+				fn(pos, path.Parts[0])
+			}
+		})
+	}
+	var walk func(*Node)
+	walk = func(p *Node) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case ComponentNode:
+				continue
+			case VariableNode, UnescapedNode, WhenNode, UnlessNode, RangeNode:
+				if e, err := c.expr(); err == nil {
+					use(c.Pos, e)
+				}
+			case TranslateNode:
+				// the body is the message; its placeholders are params
+				if _, params, err := c.message(); err == nil {
+					for _, a := range params {
+						use(c.Pos, a.Expr)
+					}
+				}
+				continue
+			}
+			for _, a := range c.Attr {
+				if a.IsExpr {
+					use(c.Pos, a.Expr)
+				}
+			}
+			// ranges read their body from the items
+			if c.Type != RangeNode {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+}
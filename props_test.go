@@ -0,0 +1,96 @@
+package restache_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestRenderProps(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{
+			data: `{@props items=[] title="Untitled" compact:boolean user:object={}}
+<h1 class="{compact ? 'small' : ''}">{title} by {user.name}</h1>
+{#items}<i>{title}</i>{/items}`,
+			expected: `export default function ($0) {const { items = [], title = "Untitled", compact, user = {} } = $0;return <><h1 className={ compact ? 'small' : '' }>{title} by {user?.name}</h1>{(items ?? []).map($1 => <i key={ $1.key }>{$1.title}</i>)}</>;}`,
+		},
+		{
+			data:     "<!-- header -->\n{@props}\n<p>{x}</p>",
+			expected: "export default function ($0) {return <p>{$0.x}</p>;}",
+		},
+		{
+			data: `<p>{label}</p><template name="badge">{@props label count:number=0}<b>{label}: {count}</b></template>`,
+			expected: "export default function ($0) {return <p>{$0.label}</p>;}\n" +
+				"export function Badge($0) {const { label, count = 0 } = $0;return <b>{label}: {count}</b>;}",
+		},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.Render(&sb, parseNode(t, tc.data)); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if got := sb.String(); got != tc.expected {
+				t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseProps(t *testing.T) {
+	opts := restache.ParseOptions{Filters: []string{"upper"}}
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{`{@props title:string="x" n:number=-1 a:any=null}`, ""},
+		{"<p></p>{@props a}", `restache: 1:8: props must be declared at the start of a component`},
+		{"{@props a}{@props b}", `restache: 1:11: props must be declared at the start of a component`},
+		{"<div>{@props a}</div>", `restache: 1:6: props must be declared at the start of a component`},
+		{"{@props a a}", `restache: 1:1: duplicate prop a in "a a"`},
+		{"{@props a:date}", `restache: 1:1: unknown type "date" of prop a in "a:date"`},
+		{"{@props a=b}", `restache: 1:1: default of prop a must be a literal in "a=b"`},
+		{"{@props $a}", `restache: 1:1: reserved prop name $a in "$a"`},
+		{"{@props class}", `restache: 1:1: reserved prop name class in "class"`},
+		{"{@props a default=1}", `restache: 1:1: reserved prop name default in "a default=1"`},
+		{"{@props upper}", `restache: 1:1: prop upper has the name of a filter`},
+		{"{@slots a}", `restache: 1:1: unknown directive "slots"`},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			_, err := restache.ParseWithOptions(strings.NewReader(tc.data), opts)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("got error %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUndeclaredProps(t *testing.T) {
+	root := parseNode(t, `{@props title items}
+<h1 title="{tooltip}">{title} {title.sub}</h1>
+{?visible}{#items}<i>{name}</i>{/items}{/visible}
+<t key="hi">Hello {name}</t>
+<template name="row"><tr>{cells}</tr></template>
+<template name="cell">{@props}<td>{value}</td></template>`)
+
+	var got []string
+	for _, err := range restache.UndeclaredProps(root) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		`restache: 2:1: undeclared prop "tooltip"`,
+		`restache: 3:1: undeclared prop "visible"`,
+		`restache: 4:1: undeclared prop "name"`,
+		`restache: 6:35: undeclared prop "value"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -2,24 +2,98 @@ package restache
 
 import (
 	"bufio"
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 
 	"golang.org/x/net/html/atom"
 )
 
 func Render(w io.Writer, n *Node) (int, error) {
+	return RenderWithOptions(w, n, RenderOptions{})
+}
+
+// A Target is the kind of module components are rendered to.
+type Target int
+
+const (
+	// TargetReact renders React components, written in JSX unless
+	// RenderOptions.CreateElement is set.
+	TargetReact Target = iota
+	// TargetLit renders functions returning lit-html templates. The module
+	// is expected to import html and nothing from lit, and the repeat and
+	// unsafeHTML directives if it uses them.
+	TargetLit
+	// TargetDOM renders functions building DOM nodes, which return them with
+	// a function patching them for new props. The module is expected to
+	// include DOMRuntime.
+	TargetDOM
+)
+
+// RenderOptions configures RenderWithOptions.
+type RenderOptions struct {
+	// Target is the kind of module rendered.
+	Target Target
+
+	// CustomElement, with TargetLit, also defines the top-level component
+	// as a custom element of this name, a LitElement that renders it with
+	// its properties as props.
+	CustomElement string
+
+	// StrictAccess emits plain property access. By default, nested paths use
+	// optional chaining and ranges iterate over an empty array when the list
+	// is missing, so that absent values render nothing, as in Mustache.
+	StrictAccess bool
+
+	// Sanitizer names a function in scope of the generated module that is
+	// applied to unescaped HTML before it is inserted.
+	Sanitizer string
+
+	// Translator names a function in scope of the generated module that
+	// translates messages. It is called as Translator(key, params, fallback),
+	// where fallback is the default message or an object of plural forms.
+	// If empty, translations render their default message.
+	Translator string
+
+	// TranslateText routes every untagged text node through Translator,
+	// keyed by its content.
+	TranslateText bool
+
+	// Memo wraps every component in React.memo, and ForwardRef in
+	// React.forwardRef, in addition to those marked by their pragmas.
+	Memo       bool
+	ForwardRef bool
+
+	// Fragment names the component of keyed fragments, and MemoFunc and
+	// ForwardRefFunc the functions wrapping components. They default to
+	// React.Fragment, React.memo and React.forwardRef.
+	Fragment       string
+	MemoFunc       string
+	ForwardRefFunc string
+
+	// CreateElement names the function elements are created with, such as
+	// React.createElement. If set, the output is plain JavaScript calling it
+	// instead of JSX, with fragments created from Fragment.
+	CreateElement string
+
+	// Classes maps class names to those they are given by the component's
+	// styles. Names in static class attributes are replaced with it.
+	Classes map[string]string
+}
+
+func RenderWithOptions(w io.Writer, n *Node, opts RenderOptions) (int, error) {
 	if x, ok := w.(writer); ok {
-		r := &renderer{w: x}
+		r := &renderer{w: x, opts: opts}
 		if err := r.render(n); err != nil {
 			return 0, err
 		}
 		return r.written, nil
 	}
 	buf := bufio.NewWriter(w)
-	r := &renderer{w: buf}
+	r := &renderer{w: buf, opts: opts}
 	if err := r.render(n); err != nil {
 		return 0, err
 	}
@@ -37,6 +111,10 @@ var (
 	ErrChildOnly       = errors.New("node must appear inside an element node")
 	ErrTopLevelOnly    = errors.New("node must appear at the top level")
 	ErrMissingBody     = errors.New("node must have children")
+	ErrUnknownExpr     = errors.New("unknown expression type")
+	ErrSoleChildOnly   = errors.New("node must be the only child of an element")
+	ErrNoRef           = errors.New("@ref used by a component without forwardRef")
+	ErrUnnamedElement  = errors.New("custom element component has no name")
 )
 
 type writer interface {
@@ -46,10 +124,14 @@ type writer interface {
 }
 
 type renderer struct {
-	w writer
+	w    writer
+	opts RenderOptions
 
 	written int
 	scope   int
+	props   []Prop   // declared props of the component being rendered
+	names   []string // identifiers its script declares
+	ref     bool     // whether it receives a ref
 
 	inExpr bool
 }
@@ -82,54 +164,568 @@ func (r *renderer) renderText(n *Node) error {
 		return ErrChildOnly
 	}
 	s := n.Data
-	if s[0] == ' ' && n.PrevSibling == nil {
-		s = s[1:]
+	if s == "" {
+		return nil
+	}
+	if r.createsElements() {
+		return r.renderTextArg(n)
+	}
+	if n.isRawText() {
+		return r.print("{`" + escapeTemplateLiteral(s) + "`}")
 	}
-	x := len(s)
-	if s[x-1] == ' ' && n.NextSibling == nil {
-		s = s[:x-1]
+	if r.opts.Translator != "" && r.opts.TranslateText && strings.TrimSpace(s) != "" {
+		return r.renderTranslatedText(s)
+	}
+	if jsxAltersText(s) {
+		return r.printf("{%s}", jsString(s))
 	}
 	return r.print(s)
 }
 
-func (r *renderer) renderVariable(n *Node) (err error) {
-	if err = r.printf("$%d.", r.scope); err == nil {
-		err = r.print(n.Data)
+// jsxAltersText reports whether JSX would not reproduce s verbatim as text:
+// whitespace-only text and text with newlines, tabs or runs of spaces must
+// be written as string expressions.
+func jsxAltersText(s string) bool {
+	if strings.TrimLeft(s, " ") == "" {
+		return true
 	}
-	return
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n', '\r', '\t', '\f':
+			return true
+		case ' ':
+			if i+1 < len(s) && s[i+1] == ' ' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderTranslatedText prints s as a call to the translator, keeping its
+// surrounding space outside of the message.
+func (r *renderer) renderTranslatedText(s string) error {
+	msg := strings.TrimSpace(s)
+	if msg == "" {
+		return r.print(s)
+	}
+	if s[0] == ' ' {
+		if err := r.print1(' '); err != nil {
+			return err
+		}
+	}
+	if err := r.printf("{%s(%s)}", r.opts.Translator, jsString(msg)); err != nil {
+		return err
+	}
+	if s[len(s)-1] == ' ' {
+		return r.print1(' ')
+	}
+	return nil
+}
+
+func (r *renderer) renderTranslate(n *Node) error {
+	m, params, err := n.message()
+	if err != nil {
+		return err
+	}
+	if r.opts.Translator != "" {
+		if err := r.printf("%s(%s", r.opts.Translator, jsString(m.Key)); err != nil {
+			return err
+		}
+		if len(params) > 0 || m.Text != "" || m.Plurals != nil {
+			if err := r.print(", {"); err != nil {
+				return err
+			}
+			for i, a := range params {
+				if i > 0 {
+					if err := r.print1(','); err != nil {
+						return err
+					}
+				}
+				if err := r.renderParam(a); err != nil {
+					return err
+				}
+			}
+			if err := r.print(" }"); err != nil {
+				return err
+			}
+		}
+		if m.Plurals != nil {
+			if err := r.print(", {"); err != nil {
+				return err
+			}
+			first := true
+			for _, cat := range pluralCategories {
+				if form, ok := m.Plurals[cat]; ok {
+					if !first {
+						if err := r.print1(','); err != nil {
+							return err
+						}
+					}
+					if err := r.printf(" %s: %s", cat, jsString(form)); err != nil {
+						return err
+					}
+					first = false
+				}
+			}
+			if err := r.print(" }"); err != nil {
+				return err
+			}
+		} else if m.Text != "" {
+			if err := r.printf(", %s", jsString(m.Text)); err != nil {
+				return err
+			}
+		}
+		return r.print1(')')
+	}
+
+	// no runtime; interpolate the default message
+	if err := m.checkDefault(); err != nil {
+		return err
+	}
+	if m.Plurals == nil {
+		if m.Text == "" {
+			return r.print(jsString(m.Key))
+		}
+		return r.renderMessage(m.Text, params)
+	}
+	var count Attribute
+	for _, a := range params {
+		if a.Key == "count" {
+			count = a
+		}
+	}
+	if err := r.print1('('); err != nil {
+		return err
+	}
+	for i, cat := range []string{"zero", "one", "two"} {
+		form, ok := m.Plurals[cat]
+		if !ok {
+			continue
+		}
+		if err := r.renderParamValue(count, 9); err != nil {
+			return err
+		}
+		if err := r.printf(" === %d ? ", i); err != nil {
+			return err
+		}
+		if err := r.renderMessage(form, params); err != nil {
+			return err
+		}
+		if err := r.print(" : "); err != nil {
+			return err
+		}
+	}
+	if err := r.renderMessage(m.Plurals["other"], params); err != nil {
+		return err
+	}
+	return r.print1(')')
+}
+
+// renderParam prints a as a property of the translation parameters.
+func (r *renderer) renderParam(a Attribute) error {
+	key := a.Key
+	if !isIdent(key) {
+		key = jsString(key)
+	}
+	if err := r.printf(" %s: ", key); err != nil {
+		return err
+	}
+	return r.renderParamValue(a, 2)
+}
+
+func (r *renderer) renderParamValue(a Attribute, prec int) error {
+	if !a.IsExpr {
+		return r.print(jsString(a.Val))
+	}
+	e, err := a.expr()
+	if err != nil {
+		return err
+	}
+	return r.renderExpr(e, prec)
+}
+
+// renderMessage prints msg as a template literal, substituting each {name}
+// placeholder with the value of the matching parameter.
+func (r *renderer) renderMessage(msg string, params []Attribute) error {
+	if err := r.print1('`'); err != nil {
+		return err
+	}
+	for msg != "" {
+		i := strings.IndexByte(msg, '{')
+		j := strings.IndexByte(msg[i+1:], '}') + i + 1
+		if i < 0 || j <= i {
+			break
+		}
+		if err := r.print(escapeTemplateLiteral(msg[:i])); err != nil {
+			return err
+		}
+		name := msg[i+1 : j]
+		k := slices.IndexFunc(params, func(a Attribute) bool { return a.Key == name })
+		if k < 0 {
+			if err := r.print(escapeTemplateLiteral(msg[i : j+1])); err != nil {
+				return err
+			}
+		} else {
+			if err := r.print("${"); err != nil {
+				return err
+			}
+			if err := r.renderParamValue(params[k], 0); err != nil {
+				return err
+			}
+			if err := r.print1('}'); err != nil {
+				return err
+			}
+		}
+		msg = msg[j+1:]
+	}
+	if err := r.print(escapeTemplateLiteral(msg)); err != nil {
+		return err
+	}
+	return r.print1('`')
+}
+
+var templateLiteralEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${")
+
+func escapeTemplateLiteral(s string) string {
+	return templateLiteralEscaper.Replace(s)
+}
+
+func (r *renderer) renderVariable(n *Node) error {
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	return r.renderExpr(e, 0)
+}
+
+// renderExpr prints e, parenthesized if its precedence is below prec.
+func (r *renderer) renderExpr(e Expr, prec int) error {
+	if exprPrec(e) < prec {
+		if err := r.print1('('); err != nil {
+			return err
+		}
+		if err := r.renderExpr(e, 0); err != nil {
+			return err
+		}
+		return r.print1(')')
+	}
+	switch e := e.(type) {
+	case *PathExpr:
+		parts, first := e.Parts, 0
+		if r.scope == 0 && (slices.Contains(r.names, parts[0]) ||
+			slices.ContainsFunc(r.props, func(p Prop) bool { return p.Name == parts[0] })) {
+			// a declared prop, or an identifier of the script; in a range,
+			// names are fields of the item
+			if err := r.print(parts[0]); err != nil {
+				return err
+			}
+			parts, first = parts[1:], 1
+		} else if err := r.printf("$%d", r.scope); err != nil {
+			return err
+		}
+		for i, part := range parts {
+			sep := "."
+			if i+first > 0 && !r.opts.StrictAccess {
+				sep = "?."
+			}
+			if err := r.print(sep); err != nil {
+				return err
+			}
+			if err := r.print(part); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *LiteralExpr:
+		return r.print(e.Value)
+
+	case *styleExpr:
+		return r.renderStyle(e)
+
+	case *RefExpr:
+		if !r.ref {
+			return ErrNoRef
+		}
+		return r.print("$ref")
+
+	case *UnaryExpr:
+		if err := r.print(e.Op); err != nil {
+			return err
+		}
+		return r.renderExpr(e.X, exprPrec(e))
+
+	case *BinaryExpr:
+		p := exprPrec(e)
+		if err := r.renderOperand(e, e.X, p); err != nil {
+			return err
+		}
+		if err := r.printf(" %s ", e.Op); err != nil {
+			return err
+		}
+		return r.renderOperand(e, e.Y, p+1)
+
+	case *CondExpr:
+		if err := r.renderExpr(e.Cond, 3); err != nil {
+			return err
+		}
+		if err := r.print(" ? "); err != nil {
+			return err
+		}
+		if err := r.renderExpr(e.Then, 2); err != nil {
+			return err
+		}
+		if err := r.print(" : "); err != nil {
+			return err
+		}
+		return r.renderExpr(e.Else, 2)
+
+	case *CallExpr:
+		if err := r.print(e.Func); err != nil {
+			return err
+		}
+		if err := r.print1('('); err != nil {
+			return err
+		}
+		for i, arg := range e.Args {
+			if i > 0 {
+				if err := r.print(", "); err != nil {
+					return err
+				}
+			}
+			if err := r.renderExpr(arg, 2); err != nil {
+				return err
+			}
+		}
+		return r.print1(')')
+	}
+	return ErrUnknownExpr
+}
+
+// renderOperand prints an operand x of the binary expression e. JavaScript
+// does not allow ?? to be mixed with || or && without parentheses.
+func (r *renderer) renderOperand(e *BinaryExpr, x Expr, prec int) error {
+	if b, ok := x.(*BinaryExpr); ok && (e.Op == "??") != (b.Op == "??") &&
+		(isLogicalOp(e.Op) && isLogicalOp(b.Op)) {
+		prec = 21
+	}
+	return r.renderExpr(x, prec)
+}
+
+func isLogicalOp(op string) bool {
+	return op == "??" || op == "||" || op == "&&"
 }
 
+// renderComponent prints the module of the top-level component n: its
+// imports, its default export if it has a body, and a named export for each
+// of its child components. A child component renders as its export alone.
 func (r *renderer) renderComponent(n *Node) error {
-	if n.Parent != nil || n.PrevSibling != nil || n.NextSibling != nil {
+	if n.Parent != nil {
+		if n.Parent.Type != ComponentNode || n.Parent.Parent != nil {
+			return ErrTopLevelOnly
+		}
+		return r.renderExport(n, false)
+	}
+	if n.PrevSibling != nil || n.NextSibling != nil {
 		return ErrTopLevelOnly
 	}
 	for _, attr := range n.Attr {
+		if attr.Key == "" {
+			// imported for its side effects
+			if err := r.printf("import '%s';\n", attr.Val); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := r.printf("import %s from '%s';\n", attr.Key, attr.Val); err != nil {
 			return err
 		}
 	}
-	if err := r.printf("export default function %s($%d) {", n.Data, r.scope); err != nil {
+	var script *componentScript
+	if s := n.script(); s != nil {
+		script = parseScript(s.Data)
+		for _, decl := range script.imports {
+			if err := r.print(decl + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	sep := false
+	if n.body() != nil || n.FirstChild == nil {
+		if err := r.renderExport(n, true); err != nil {
+			return err
+		}
+		if r.opts.Target == TargetLit && r.opts.CustomElement != "" {
+			if err := r.renderLitElementClass(n, r.opts.CustomElement); err != nil {
+				return err
+			}
+		}
+		sep = true
+	} else if script != nil && script.body != "" {
+		// without a default component, the script is module code
+		if err := r.print(script.body); err != nil {
+			return err
+		}
+		sep = true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != ComponentNode {
+			continue
+		}
+		if sep {
+			if err := r.print1('\n'); err != nil {
+				return err
+			}
+		}
+		if err := r.renderExport(c, false); err != nil {
+			return err
+		}
+		sep = true
+	}
+	return nil
+}
+
+// renderExport prints the export of the component n, as the default export
+// if def is set, wrapped in React.memo and React.forwardRef as configured.
+func (r *renderer) renderExport(n *Node, def bool) error {
+	memo := n.Memo != nil || r.opts.Memo
+	ref := n.ForwardRef || r.opts.ForwardRef
+	if !memo && !ref || r.opts.Target != TargetReact {
+		decl := "export function"
+		if def {
+			decl = "export default function"
+		}
+		return r.renderFunction(decl, n, false)
+	}
+	decl := "export default "
+	if !def {
+		decl = "export const " + n.Data + " = "
+	}
+	if err := r.print(decl); err != nil {
 		return err
 	}
-	first := n.FirstChild
-	if first != nil {
-		if err := r.print("return "); err != nil {
+	if memo {
+		if err := r.print(cmp.Or(r.opts.MemoFunc, "React.memo") + "("); err != nil {
 			return err
 		}
-		if err := r.render(first); err != nil {
+	}
+	if ref {
+		if err := r.print(cmp.Or(r.opts.ForwardRefFunc, "React.forwardRef") + "("); err != nil {
 			return err
 		}
-		if err := r.print(";}"); err != nil {
+	}
+	if err := r.renderFunction("function", n, ref); err != nil {
+		return err
+	}
+	if ref {
+		if err := r.print1(')'); err != nil {
 			return err
 		}
-	} else {
-		if err := r.print("return null;}"); err != nil {
+	}
+	if memo {
+		if len(n.Memo) > 0 {
+			// compare only the listed props
+			if err := r.print(", (prev, next) => "); err != nil {
+				return err
+			}
+			for i, name := range n.Memo {
+				if i > 0 {
+					if err := r.print(" && "); err != nil {
+						return err
+					}
+				}
+				if err := r.printf("prev.%s === next.%s", name, name); err != nil {
+					return err
+				}
+			}
+		}
+		if err := r.print1(')'); err != nil {
 			return err
 		}
 	}
+	if !def {
+		return r.print1(';')
+	}
 	return nil
 }
 
+// renderFunction prints the component n as a function declared by decl,
+// taking a ref as its second parameter if ref is set.
+func (r *renderer) renderFunction(decl string, n *Node, ref bool) error {
+	if r.opts.Target == TargetDOM {
+		return (&domRenderer{renderer: r}).renderFunction(decl, n)
+	}
+	params := fmt.Sprintf("$%d", r.scope)
+	if ref {
+		params += ", $ref"
+	}
+	if err := r.printf("%s %s(%s) {", decl, n.Data, params); err != nil {
+		return err
+	}
+	r.props, r.names, r.ref = n.Props, n.scriptNames(), ref
+	defer func() { r.props, r.names, r.ref = nil, nil, false }()
+	if err := r.renderProps(); err != nil {
+		return err
+	}
+	if s := n.script(); s != nil {
+		if body := parseScript(s.Data).body; body != "" {
+			if err := r.print("\n" + body + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	if body := n.body(); body != nil {
+		if err := r.print("return "); err != nil {
+			return err
+		}
+		var err error
+		if r.opts.Target == TargetLit {
+			err = r.renderLitTemplate(body)
+		} else {
+			err = r.render(body)
+		}
+		if err != nil {
+			return err
+		}
+		return r.print(";}")
+	}
+	if r.opts.Target == TargetLit {
+		return r.print("return nothing;}")
+	}
+	return r.print("return null;}")
+}
+
+// renderProps declares the props of the current component as constants,
+// applying their defaults.
+func (r *renderer) renderProps() error {
+	if len(r.props) == 0 {
+		return nil
+	}
+	if err := r.print("const { "); err != nil {
+		return err
+	}
+	for i, p := range r.props {
+		if i > 0 {
+			if err := r.print(", "); err != nil {
+				return err
+			}
+		}
+		if err := r.print(p.Name); err != nil {
+			return err
+		}
+		if p.Default != nil {
+			if err := r.print(" = "); err != nil {
+				return err
+			}
+			if err := r.renderExpr(p.Default, 2); err != nil {
+				return err
+			}
+		}
+	}
+	return r.printf(" } = $%d;", r.scope)
+}
+
 func (r *renderer) renderWhen(n *Node, negate bool) error {
 	if n.FirstChild == nil {
 		return ErrMissingBody
@@ -142,7 +738,18 @@ func (r *renderer) renderWhen(n *Node, negate bool) error {
 			return err
 		}
 	}
-	if err := r.printf("$%d.%s && ", r.scope, n.Data); err != nil {
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	prec := 4 // left operand of &&
+	if negate {
+		prec = 14 // operand of !
+	}
+	if err := r.renderExpr(e, prec); err != nil {
+		return err
+	}
+	if err := r.print(" && "); err != nil {
 		return err
 	}
 	if n.FirstChild != nil && n.FirstChild == n.LastChild {
@@ -162,7 +769,26 @@ func (r *renderer) renderRange(n *Node) error {
 	if n.FirstChild == nil {
 		return ErrMissingBody
 	}
-	if err := r.printf("$%d.%s.map(", r.scope, n.Data); err != nil {
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if r.opts.StrictAccess {
+		if err := r.renderExpr(e, 17); err != nil { // member access
+			return err
+		}
+	} else {
+		if err := r.print1('('); err != nil {
+			return err
+		}
+		if err := r.renderExpr(e, 5); err != nil { // operand of ??
+			return err
+		}
+		if err := r.print(" ?? [])"); err != nil {
+			return err
+		}
+	}
+	if err := r.print(".map("); err != nil {
 		return err
 	}
 	r.scope++
@@ -195,12 +821,47 @@ func (r *renderer) renderAttribute(a Attribute, key string) error {
 			return nil
 		}
 	}
+	if a.KeyAtom == atom.Class && !a.IsExpr && r.opts.Classes != nil {
+		a.Val = scopedClassNames(a.Val, r.opts.Classes)
+	}
 	if a.IsExpr {
-		return r.printf(`={ $%d.%s }`, r.scope, a.Val)
+		if err := r.print("={ "); err != nil {
+			return err
+		}
+		e := a.Expr
+		if e == nil {
+			var err error
+			if e, err = ParseExpr(a.Val); err != nil {
+				return err
+			}
+		}
+		if err := r.renderExpr(e, 0); err != nil {
+			return err
+		}
+		return r.print(" }")
 	}
 	return r.printf(`="%s"`, a.Val)
 }
 
+// attrKey returns the JSX name of the attribute a of the element n.
+func attrKey(n *Node, a Attribute) string {
+	if a.KeyAtom == 0 {
+		return a.Key
+	}
+	if n.CustomElement {
+		return a.KeyAtom.String()
+	}
+	if alias, ok := globalCamelAttrTable[a.KeyAtom]; ok {
+		return alias
+	}
+	if _, found := camelAttrTags[n.DataAtom]; found {
+		if alias, ok := camelAttrTable[uint64(n.DataAtom)<<32|uint64(a.KeyAtom)]; ok {
+			return alias
+		}
+	}
+	return a.KeyAtom.String()
+}
+
 func (r *renderer) renderElement(n *Node) error {
 	// <tag
 	if err := r.print1('<'); err != nil {
@@ -210,45 +871,17 @@ func (r *renderer) renderElement(n *Node) error {
 	tagName := n.Data
 	if n.DataAtom != 0 {
 		tagName = n.DataAtom.String()
+	} else if tagName == keyedFragment && r.opts.Fragment != "" {
+		tagName = r.opts.Fragment
 	}
 	if err := r.print(tagName); err != nil {
 		return err
 	}
 
 	// attributes
-	if len(n.Attr) > 0 {
-		if _, found := camelAttrTags[n.DataAtom]; found {
-			searchPrefix := uint64(n.DataAtom) << 32
-			for _, a := range n.Attr {
-				if a.KeyAtom != 0 {
-					if alias, ok := globalCamelAttrTable[a.KeyAtom]; ok {
-						a.Key = alias
-					} else if alias, ok := camelAttrTable[searchPrefix|uint64(a.KeyAtom)]; ok {
-						a.Key = alias
-					} else {
-						a.Key = a.KeyAtom.String()
-					}
-				}
-				if err := r.renderAttribute(a, a.Key); err != nil {
-					return err
-				}
-			}
-		} else {
-			for _, a := range n.Attr {
-				var key string
-				if a.KeyAtom != 0 {
-					if alias, ok := globalCamelAttrTable[a.KeyAtom]; ok {
-						key = alias
-					} else {
-						key = a.KeyAtom.String()
-					}
-				} else {
-					key = a.Key
-				}
-				if err := r.renderAttribute(a, key); err != nil {
-					return err
-				}
-			}
+	for _, a := range n.Attr {
+		if err := r.renderAttribute(a, attrKey(n, a)); err != nil {
+			return err
 		}
 	}
 
@@ -262,22 +895,17 @@ func (r *renderer) renderElement(n *Node) error {
 		}
 	}
 
+	// unescaped HTML replaces the children
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil && tagName != "" {
+		return r.renderUnescaped(c)
+	}
+
 	// non-void: children
 	if err := r.print1('>'); err != nil {
 		return err
 	}
 
 	if n.FirstChild != nil {
-		// extra newline for <pre>, <listing>, <textarea> when first child starts with '\n'
-		if n.FirstChild.Type == TextNode && strings.HasPrefix(n.FirstChild.Data, "\n") {
-			switch n.DataAtom {
-			case atom.Pre, atom.Listing, atom.Textarea:
-				if err := r.print1('\n'); err != nil {
-					return err
-				}
-			}
-		}
-
 		// enter JSX context
 		saved := r.inExpr
 		r.inExpr = false
@@ -300,6 +928,23 @@ func (r *renderer) renderElement(n *Node) error {
 	return r.print1('>')
 }
 
+func (r *renderer) renderUnescaped(n *Node) error {
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if err := r.print(" dangerouslySetInnerHTML={{ __html: "); err != nil {
+		return err
+	}
+	if r.opts.Sanitizer != "" {
+		e = &CallExpr{Func: r.opts.Sanitizer, Args: []Expr{e}}
+	}
+	if err := r.renderExpr(e, 2); err != nil {
+		return err
+	}
+	return r.print(" }} />")
+}
+
 func (r *renderer) renderComment(n *Node) error {
 	if n.Parent != nil && n.Parent.Type != ElementNode {
 		return ErrChildOnly
@@ -365,6 +1010,17 @@ func (r *renderer) renderChildren(p *Node) error {
 				return err
 			}
 
+		case TranslateNode:
+			if err := r.enterExpr(); err != nil {
+				return err
+			}
+			if err := r.renderTranslate(c); err != nil {
+				return err
+			}
+			if err := r.leaveExpr(); err != nil {
+				return err
+			}
+
 		case RangeNode:
 			if err := r.enterExpr(); err != nil {
 				return err
@@ -392,6 +1048,9 @@ func (r *renderer) render(n *Node) error {
 	case TextNode:
 		return r.renderText(n)
 	case ElementNode:
+		if r.createsElements() {
+			return r.renderElementCall(n)
+		}
 		return r.renderElement(n)
 	case VariableNode:
 		return r.renderVariable(n)
@@ -403,6 +1062,10 @@ func (r *renderer) render(n *Node) error {
 		return r.renderRange(n)
 	case CommentNode:
 		return r.renderComment(n)
+	case UnescapedNode:
+		return ErrSoleChildOnly
+	case TranslateNode:
+		return r.renderTranslate(n)
 	case ComponentNode:
 		return r.renderComponent(n)
 	default:
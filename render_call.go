@@ -0,0 +1,179 @@
+package restache
+
+import (
+	"cmp"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// The renderer prints plain JavaScript instead of JSX when
+// RenderOptions.CreateElement is set: elements become calls of the form
+// CreateElement(type, props, ...children).
+
+func (r *renderer) createsElements() bool {
+	return r.opts.CreateElement != ""
+}
+
+// elementType returns the JavaScript value of the type of the element n:
+// a string for HTML elements, and an identifier for components and
+// fragments, following the rules of JSX.
+func (r *renderer) elementType(n *Node) string {
+	if n.DataAtom != 0 {
+		return jsString(n.DataAtom.String())
+	}
+	if n.Data == "" || n.Data == keyedFragment {
+		return cmp.Or(r.opts.Fragment, keyedFragment)
+	}
+	if c := n.Data[0]; 'a' <= c && c <= 'z' || strings.ContainsAny(n.Data, "-:") {
+		return jsString(n.Data)
+	}
+	return n.Data
+}
+
+func (r *renderer) renderElementCall(n *Node) error {
+	if err := r.printf("%s(%s, ", r.opts.CreateElement, r.elementType(n)); err != nil {
+		return err
+	}
+
+	var unescaped *Node
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil && n.TagName() != "" {
+		unescaped = c
+	}
+
+	// props
+	if len(n.Attr) == 0 && unescaped == nil {
+		if err := r.print("null"); err != nil {
+			return err
+		}
+	} else {
+		if err := r.print1('{'); err != nil {
+			return err
+		}
+		for i, a := range n.Attr {
+			if i > 0 {
+				if err := r.print1(','); err != nil {
+					return err
+				}
+			}
+			if err := r.renderProp(a, attrKey(n, a)); err != nil {
+				return err
+			}
+		}
+		if unescaped != nil {
+			if len(n.Attr) > 0 {
+				if err := r.print1(','); err != nil {
+					return err
+				}
+			}
+			if err := r.renderUnescapedProp(unescaped); err != nil {
+				return err
+			}
+		}
+		if err := r.print(" }"); err != nil {
+			return err
+		}
+	}
+
+	// children
+	if n.DataAtom != 0 {
+		if _, ok := voidElements[n.DataAtom]; ok && n.FirstChild != nil {
+			return ErrVoidChildren
+		}
+	}
+	if unescaped == nil {
+		if err := r.renderChildArgs(n); err != nil {
+			return err
+		}
+	}
+	return r.print1(')')
+}
+
+// renderProp prints the attribute a as the property key of a props object.
+func (r *renderer) renderProp(a Attribute, key string) error {
+	if !isIdent(key) {
+		key = jsString(key)
+	}
+	if err := r.printf(" %s: ", key); err != nil {
+		return err
+	}
+	if a.Val == "" && a.KeyAtom != 0 {
+		if _, ok := boolAttrs[a.KeyAtom]; ok {
+			return r.print("true")
+		}
+	}
+	if a.KeyAtom == atom.Class && !a.IsExpr && r.opts.Classes != nil {
+		a.Val = scopedClassNames(a.Val, r.opts.Classes)
+	}
+	return r.renderParamValue(a, 2)
+}
+
+func (r *renderer) renderUnescapedProp(n *Node) error {
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if r.opts.Sanitizer != "" {
+		e = &CallExpr{Func: r.opts.Sanitizer, Args: []Expr{e}}
+	}
+	if err := r.print(" dangerouslySetInnerHTML: { __html: "); err != nil {
+		return err
+	}
+	if err := r.renderExpr(e, 2); err != nil {
+		return err
+	}
+	return r.print(" }")
+}
+
+// renderChildArgs prints the children of p as the trailing arguments of a
+// call. Comments are kept in between as JavaScript comments.
+func (r *renderer) renderChildArgs(p *Node) error {
+	for c := p.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == CommentNode:
+			if err := r.print(" /* "); err != nil {
+				return err
+			}
+			if err := escapeComment(r.w, c.Data); err != nil {
+				return err
+			}
+			if err := r.print(" */"); err != nil {
+				return err
+			}
+		case c.Type == TextNode && c.Data == "":
+		default:
+			if err := r.print(", "); err != nil {
+				return err
+			}
+			if err := r.render(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderTextArg prints the text node n as one or more call arguments.
+func (r *renderer) renderTextArg(n *Node) error {
+	s := n.Data
+	if n.isRawText() {
+		return r.print("`" + escapeTemplateLiteral(s) + "`")
+	}
+	msg := strings.TrimSpace(s)
+	if r.opts.Translator == "" || !r.opts.TranslateText || msg == "" {
+		return r.print(jsString(s))
+	}
+	// keep the surrounding space outside of the message
+	if s[0] == ' ' {
+		if err := r.print(`" ", `); err != nil {
+			return err
+		}
+	}
+	if err := r.printf("%s(%s)", r.opts.Translator, jsString(msg)); err != nil {
+		return err
+	}
+	if s[len(s)-1] == ' ' {
+		return r.print(`, " "`)
+	}
+	return nil
+}
@@ -0,0 +1,550 @@
+package restache
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// DOMRuntime defines the functions that components rendered with TargetDOM
+// call. A module of such components is expected to include it.
+//
+// A component is a function of its props returning an object with the node
+// it created, an element or a fragment of its nodes, and an update function
+// of new props that patches them in place. Every dynamic part of a template
+// is delimited by comment markers; ranges keep the nodes of each item, keyed
+// by its key property or else its index, and reorder them as the list
+// changes.
+const DOMRuntime = `function $text(v) {
+  return v == null || v === false ? "" : String(v);
+}
+function $attr(el, name, v) {
+  if (v == null || v === false) el.removeAttribute(name);
+  else el.setAttribute(name, v === true ? "" : v);
+}
+function $prop(el, name, v) {
+  if (el[name] !== v) el[name] = v;
+}
+function $on(el, type, fn) {
+  const fns = el.$on ??= {};
+  if (!(type in fns)) el.addEventListener(type, e => fns[type]?.(e));
+  fns[type] = fn;
+}
+function $html(el, v) {
+  v = $text(v);
+  if (el.$html !== v) el.innerHTML = el.$html = v;
+}
+function $marker(parent) {
+  const m = document.createComment("");
+  parent.append(m);
+  return m;
+}
+function $clear(start, end) {
+  while (start.nextSibling !== end) start.nextSibling.remove();
+}
+function $value(parent) {
+  const start = $marker(parent), end = $marker(parent);
+  let cur, text = null;
+  return v => {
+    if (v instanceof Node) {
+      if (v !== cur) {
+        $clear(start, end);
+        end.before(v);
+        cur = v, text = null;
+      }
+      return;
+    }
+    if (!text) {
+      $clear(start, end);
+      text = document.createTextNode("");
+      end.before(text);
+      cur = undefined;
+    }
+    const s = $text(v);
+    if (text.data !== s) text.data = s;
+  };
+}
+function $when(parent, view) {
+  const start = $marker(parent), end = $marker(parent);
+  let update = null;
+  return (show, ...scope) => {
+    if (!show) {
+      if (update) $clear(start, end);
+      update = null;
+    } else if (update) {
+      update(...scope);
+    } else {
+      let node;
+      [node, update] = view(...scope);
+      end.before(node);
+    }
+  };
+}
+function $range(parent, view) {
+  const start = $marker(parent), end = $marker(parent);
+  let items = new Map();
+  return (list, ...scope) => {
+    const next = new Map();
+    let i = 0;
+    for (const item of list) {
+      let key = item?.key ?? i;
+      i++;
+      if (next.has(key)) key = {};
+      let entry = items.get(key);
+      if (entry) {
+        items.delete(key);
+        entry.update(...scope, item);
+      } else {
+        const [node, update] = view(...scope, item);
+        const start = document.createComment("");
+        node.prepend(start);
+        entry = { start, end: $marker(node), node, update };
+      }
+      next.set(key, entry);
+    }
+    for (const entry of items.values()) {
+      $clear(entry.start, entry.end);
+      entry.start.remove();
+      entry.end.remove();
+    }
+    let at = start.nextSibling;
+    for (const entry of next.values()) {
+      if (entry.node) {
+        at.before(entry.node);
+        entry.node = null;
+      } else if (entry.start === at) {
+        at = entry.end.nextSibling;
+      } else {
+        for (let n = entry.start; ; ) {
+          const next = n.nextSibling;
+          at.before(n);
+          if (n === entry.end) break;
+          n = next;
+        }
+      }
+    }
+    items = next;
+  };
+}
+function $component(parent, component) {
+  const end = $marker(parent);
+  let instance = null;
+  return props => {
+    if (instance) instance.update(props);
+    else end.before((instance = component(props)).node);
+  };
+}
+function $mount(view, props) {
+  const [node, update] = view(props);
+  return { node: node.childNodes.length === 1 ? node.firstChild : node, update };
+}
+`
+
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// A domRenderer renders components as functions building their nodes with
+// the DOM API. Each template, and each section of it, becomes a view: a
+// function of the scope that creates the static nodes in a fragment once,
+// and returns it with an update function of the scope that patches the
+// dynamic parts.
+type domRenderer struct {
+	*renderer
+	ids int  // of the variables of nodes and parts
+	svg bool // whether elements are created in the SVG namespace
+}
+
+// A domView collects the statements of a view.
+type domView struct {
+	create strings.Builder
+	update strings.Builder
+}
+
+func (d *domRenderer) id(prefix string) string {
+	d.ids++
+	return fmt.Sprintf("$%s%d", prefix, d.ids)
+}
+
+// params returns the parameters of the views in the current scope.
+func (d *domRenderer) params() string {
+	params := make([]string, d.scope+1)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", i)
+	}
+	return strings.Join(params, ", ")
+}
+
+// capture returns what fn prints.
+func (d *domRenderer) capture(fn func() error) (string, error) {
+	var b strings.Builder
+	w, written := d.w, d.written
+	d.w = &b
+	err := fn()
+	d.w, d.written = w, written
+	return b.String(), err
+}
+
+func (d *domRenderer) expr(e Expr) (string, error) {
+	return d.capture(func() error { return d.renderExpr(e, 2) }) // argument
+}
+
+// renderFunction prints the component n, which runs its script once, when
+// created, and assigns its declared props on every update.
+func (d *domRenderer) renderFunction(decl string, n *Node) error {
+	if err := d.printf("%s %s($%d) {", decl, n.Data, d.scope); err != nil {
+		return err
+	}
+	d.props, d.names = n.Props, n.scriptNames()
+	defer func() { d.props, d.names = nil, nil }()
+	var assign string
+	if len(d.props) > 0 {
+		var b strings.Builder
+		b.WriteString("{ ")
+		for i, p := range d.props {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(p.Name)
+			if p.Default != nil {
+				def, err := d.expr(p.Default)
+				if err != nil {
+					return err
+				}
+				b.WriteString(" = " + def)
+			}
+		}
+		fmt.Fprintf(&b, " } = $%d", d.scope)
+		assign = b.String()
+		if err := d.printf("let %s;", assign); err != nil {
+			return err
+		}
+		assign = "(" + assign + ");"
+	}
+	if s := n.script(); s != nil {
+		if body := parseScript(s.Data).body; body != "" {
+			if err := d.print("\n" + body + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	view, err := d.view(assign, func(v *domView, parent string) error {
+		if body := n.body(); body != nil {
+			return d.renderNode(v, parent, body)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return d.printf("return $mount(%s, $%d);}", view, d.scope)
+}
+
+// view returns a view of the nodes rendered by fn into parent. Its update
+// function starts with the statements in prologue.
+func (d *domRenderer) view(prologue string, fn func(v *domView, parent string) error) (string, error) {
+	v := &domView{}
+	frag := d.id("n")
+	fmt.Fprintf(&v.create, "const %s = document.createDocumentFragment();", frag)
+	v.update.WriteString(prologue)
+	if err := fn(v, frag); err != nil {
+		return "", err
+	}
+	params := d.params()
+	return fmt.Sprintf("(%s) => {%sconst $update = (%s) => {%s};$update(%s);return [%s, $update];}",
+		params, v.create.String(), params, v.update.String(), params, frag), nil
+}
+
+func (d *domRenderer) renderChildren(v *domView, parent string, p *Node) error {
+	for c := p.FirstChild; c != nil; c = c.NextSibling {
+		if err := d.renderNode(v, parent, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderNode adds the statements creating and updating n, appended to
+// parent, to v.
+func (d *domRenderer) renderNode(v *domView, parent string, n *Node) error {
+	switch n.Type {
+	case ErrorNode:
+		return ErrErrorNode
+	case TextNode:
+		return d.renderText(v, parent, n)
+	case ElementNode:
+		return d.renderElement(v, parent, n)
+	case VariableNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		return d.renderValue(v, parent, func() error { return d.renderExpr(e, 2) })
+	case TranslateNode:
+		return d.renderValue(v, parent, func() error { return d.renderTranslate(n) })
+	case WhenNode, UnlessNode:
+		return d.renderWhen(v, parent, n)
+	case RangeNode:
+		return d.renderRange(v, parent, n)
+	case CommentNode:
+		fmt.Fprintf(&v.create, "%s.append(document.createComment(%s));", parent, jsString(n.Data))
+		return nil
+	case UnescapedNode:
+		return ErrSoleChildOnly
+	default:
+		return ErrUnknownNode
+	}
+}
+
+func (d *domRenderer) renderText(v *domView, parent string, n *Node) error {
+	if n.Parent != nil && n.Parent.Type != ElementNode {
+		return ErrChildOnly
+	}
+	s := n.Data
+	if s == "" {
+		return nil
+	}
+	if !n.isRawText() && d.opts.Translator != "" && d.opts.TranslateText && strings.TrimSpace(s) != "" {
+		// keep the surrounding space outside of the message
+		msg := strings.TrimSpace(s)
+		i := strings.Index(s, msg)
+		if i > 0 {
+			fmt.Fprintf(&v.create, "%s.append(%s);", parent, jsString(s[:i]))
+		}
+		if err := d.renderValue(v, parent, func() error {
+			return d.printf("%s(%s)", d.opts.Translator, jsString(msg))
+		}); err != nil {
+			return err
+		}
+		s = s[i+len(msg):]
+		if s == "" {
+			return nil
+		}
+	}
+	fmt.Fprintf(&v.create, "%s.append(%s);", parent, jsString(s))
+	return nil
+}
+
+// renderValue adds a part showing the value printed by fn, as text unless
+// it is a node.
+func (d *domRenderer) renderValue(v *domView, parent string, fn func() error) error {
+	val, err := d.capture(fn)
+	if err != nil {
+		return err
+	}
+	part := d.id("p")
+	fmt.Fprintf(&v.create, "const %s = $value(%s);", part, parent)
+	fmt.Fprintf(&v.update, "%s(%s);", part, val)
+	return nil
+}
+
+func (d *domRenderer) renderElement(v *domView, parent string, n *Node) error {
+	if n.DataAtom == 0 && (n.Data == "" || n.Data == keyedFragment) {
+		return d.renderChildren(v, parent, n)
+	}
+	if isComponentTag(n) {
+		return d.renderComponent(v, parent, n)
+	}
+
+	svg := d.svg
+	defer func() { d.svg = svg }()
+	el := d.id("n")
+	if n.DataAtom == atom.Svg {
+		d.svg = true
+	}
+	if d.svg {
+		fmt.Fprintf(&v.create, "const %s = document.createElementNS(%s, %s);", el, jsString(svgNamespace), jsString(n.TagName()))
+	} else {
+		fmt.Fprintf(&v.create, "const %s = document.createElement(%s);", el, jsString(n.TagName()))
+	}
+	if n.DataAtom == atom.ForeignObject {
+		d.svg = false
+	}
+	for _, a := range n.Attr {
+		if err := d.renderAttribute(v, el, n, a); err != nil {
+			return err
+		}
+	}
+	if _, ok := voidElements[n.DataAtom]; ok && n.DataAtom != 0 && n.FirstChild != nil {
+		return ErrVoidChildren
+	}
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil {
+		e, err := c.expr()
+		if err != nil {
+			return err
+		}
+		if d.opts.Sanitizer != "" {
+			e = &CallExpr{Func: d.opts.Sanitizer, Args: []Expr{e}}
+		}
+		val, err := d.expr(e)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&v.update, "$html(%s, %s);", el, val)
+	} else if err := d.renderChildren(v, el, n); err != nil {
+		return err
+	}
+	fmt.Fprintf(&v.create, "%s.append(%s);", parent, el)
+	return nil
+}
+
+// renderAttribute sets static attributes when the element el is created,
+// and patches the others on update: event handlers are listened to, the
+// value and checked properties of form controls are assigned, and other
+// attributes are removed when false or missing.
+func (d *domRenderer) renderAttribute(v *domView, el string, n *Node, a Attribute) error {
+	if a.Key == "key" {
+		return nil // ranges key their items themselves
+	}
+	name := htmlAttrName(n, a)
+	if !a.IsExpr {
+		val := a.Val
+		if name == "class" && d.opts.Classes != nil {
+			val = scopedClassNames(val, d.opts.Classes)
+		}
+		fmt.Fprintf(&v.create, "%s.setAttribute(%s, %s);", el, jsString(name), jsString(val))
+		return nil
+	}
+	val, err := d.capture(func() error { return d.renderParamValue(a, 2) })
+	if err != nil {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(name, "on") && len(name) > 2:
+		event := name[2:]
+		if !n.CustomElement {
+			event = strings.ToLower(strings.ReplaceAll(event, "-", ""))
+		}
+		fmt.Fprintf(&v.update, "$on(%s, %s, %s);", el, jsString(event), val)
+	case name == "value":
+		fmt.Fprintf(&v.update, "$prop(%s, \"value\", $text(%s));", el, val)
+	case name == "checked":
+		fmt.Fprintf(&v.update, "$prop(%s, \"checked\", Boolean(%s));", el, val)
+	default:
+		fmt.Fprintf(&v.update, "$attr(%s, %s, %s);", el, jsString(name), val)
+	}
+	return nil
+}
+
+// renderComponent adds a part creating the component n with its attributes
+// as props, and its children as a fragment updated with the enclosing view,
+// or as the value of the variable that is its only child.
+func (d *domRenderer) renderComponent(v *domView, parent string, n *Node) error {
+	var b strings.Builder
+	b.WriteByte('{')
+	sep := false
+	for _, a := range n.Attr {
+		if a.Key == "key" {
+			continue
+		}
+		if sep {
+			b.WriteByte(',')
+		}
+		prop, err := d.capture(func() error { return d.renderProp(a, attrKey(n, a)) })
+		if err != nil {
+			return err
+		}
+		b.WriteString(prop)
+		sep = true
+	}
+	if c := n.FirstChild; c != nil && c == n.LastChild && c.Type == VariableNode {
+		// pass the value, so an empty one is falsy as in React
+		e, err := c.expr()
+		if err != nil {
+			return err
+		}
+		value, err := d.capture(func() error { return d.renderExpr(e, 0) })
+		if err != nil {
+			return err
+		}
+		if sep {
+			b.WriteByte(',')
+		}
+		b.WriteString(" children: " + value)
+		sep = true
+	} else if n.FirstChild != nil {
+		view, err := d.view("", func(v *domView, parent string) error {
+			return d.renderChildren(v, parent, n)
+		})
+		if err != nil {
+			return err
+		}
+		children, update := d.id("n"), d.id("u")
+		fmt.Fprintf(&v.create, "const [%s, %s] = (%s)(%s);", children, update, view, d.params())
+		fmt.Fprintf(&v.update, "%s(%s);", update, d.params())
+		if sep {
+			b.WriteByte(',')
+		}
+		b.WriteString(" children: " + children)
+		sep = true
+	}
+	if sep {
+		b.WriteByte(' ')
+	}
+	b.WriteByte('}')
+	part := d.id("p")
+	fmt.Fprintf(&v.create, "const %s = $component(%s, %s);", part, parent, n.Data)
+	fmt.Fprintf(&v.update, "%s(%s);", part, b.String())
+	return nil
+}
+
+func (d *domRenderer) renderWhen(v *domView, parent string, n *Node) error {
+	if n.FirstChild == nil {
+		return ErrMissingBody
+	}
+	if n.FirstChild != n.LastChild {
+		return ErrTooManyChildren
+	}
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if n.Type == UnlessNode {
+		e = &UnaryExpr{Op: "!", X: e}
+	}
+	cond, err := d.expr(e)
+	if err != nil {
+		return err
+	}
+	view, err := d.view("", func(v *domView, parent string) error {
+		return d.renderNode(v, parent, n.FirstChild)
+	})
+	if err != nil {
+		return err
+	}
+	part := d.id("p")
+	fmt.Fprintf(&v.create, "const %s = $when(%s, %s);", part, parent, view)
+	fmt.Fprintf(&v.update, "%s(%s, %s);", part, cond, d.params())
+	return nil
+}
+
+func (d *domRenderer) renderRange(v *domView, parent string, n *Node) error {
+	if n.FirstChild == nil {
+		return ErrMissingBody
+	}
+	if n.FirstChild != n.LastChild {
+		return ErrTooManyChildren
+	}
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if !d.opts.StrictAccess {
+		e = &BinaryExpr{Op: "??", X: e, Y: &LiteralExpr{Kind: ArrayLiteral, Value: "[]"}}
+	}
+	list, err := d.expr(e)
+	if err != nil {
+		return err
+	}
+	d.scope++
+	view, err := d.view("", func(v *domView, parent string) error {
+		return d.renderNode(v, parent, n.FirstChild)
+	})
+	d.scope--
+	if err != nil {
+		return err
+	}
+	part := d.id("p")
+	fmt.Fprintf(&v.create, "const %s = $range(%s, %s);", part, parent, view)
+	fmt.Fprintf(&v.update, "%s(%s, %s);", part, list, d.params())
+	return nil
+}
@@ -0,0 +1,388 @@
+package restache
+
+import (
+	"html"
+	"strings"
+)
+
+// With TargetLit, the renderer prints lit-html templates: components return
+// html`…` tagged templates, ranges use the repeat directive, and sections
+// render nothing when their condition is false.
+
+// htmlAttrName returns the name of the attribute a of the element n as
+// written in HTML, undoing the camel case given to it for JSX.
+func htmlAttrName(n *Node, a Attribute) string {
+	if a.KeyAtom != 0 {
+		return a.KeyAtom.String()
+	}
+	lower := strings.ToLower(a.Key)
+	if lower == a.Key {
+		return a.Key
+	}
+	if h, _, _ := fnv(hash0, []byte(lower)); nonSpecCamelAttrTable[uint64(n.DataAtom)<<32|uint64(h)] == a.Key {
+		return lower
+	}
+	var b strings.Builder
+	for i := 0; i < len(a.Key); i++ {
+		if c := a.Key[i]; 'A' <= c && c <= 'Z' {
+			b.WriteByte('-')
+			b.WriteByte(c - 'A' + 'a')
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isComponentTag reports whether the element n is a component rather than
+// an HTML element, following the rules of JSX.
+func isComponentTag(n *Node) bool {
+	if n.DataAtom != 0 || n.Data == "" {
+		return false
+	}
+	c := n.Data[0]
+	return !('a' <= c && c <= 'z' || strings.ContainsAny(n.Data, "-:"))
+}
+
+// litHeader returns the imports of lit the component root needs, including
+// LitElement if it is defined as a custom element.
+func litHeader(root *Node, define bool) string {
+	names := "html, nothing"
+	if define {
+		names += ", LitElement"
+	}
+	header := importStmt("{ "+names+" }", "lit")
+	if root.hasType(RangeNode) {
+		header += importStmt("{ repeat }", "lit/directives/repeat.js")
+	}
+	if root.hasType(UnescapedNode) {
+		header += importStmt("{ unsafeHTML }", "lit/directives/unsafe-html.js")
+	}
+	return header
+}
+
+// hasType reports whether n or any of its descendants is of type t.
+func (n *Node) hasType(t NodeType) bool {
+	if n.Type == t {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.hasType(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderLitTemplate prints n as an html`…` template.
+func (r *renderer) renderLitTemplate(n *Node) error {
+	if err := r.print("html`"); err != nil {
+		return err
+	}
+	if err := r.renderLit(n); err != nil {
+		return err
+	}
+	return r.print1('`')
+}
+
+func (r *renderer) renderLitChildren(p *Node) error {
+	for c := p.FirstChild; c != nil; c = c.NextSibling {
+		if err := r.renderLit(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderLit prints n as part of the content of a template.
+func (r *renderer) renderLit(n *Node) error {
+	switch n.Type {
+	case ErrorNode:
+		return ErrErrorNode
+	case TextNode:
+		return r.renderLitText(n)
+	case ElementNode:
+		return r.renderLitElement(n)
+	case VariableNode:
+		return r.renderLitExpr(func() error { return r.renderVariable(n) })
+	case WhenNode, UnlessNode:
+		return r.renderLitExpr(func() error { return r.renderLitWhen(n) })
+	case RangeNode:
+		return r.renderLitExpr(func() error { return r.renderLitRange(n) })
+	case TranslateNode:
+		return r.renderLitExpr(func() error { return r.renderTranslate(n) })
+	case CommentNode:
+		if err := r.print("<!--"); err != nil {
+			return err
+		}
+		if err := r.print(escapeTemplateLiteral(strings.ReplaceAll(n.Data, "--", "- -"))); err != nil {
+			return err
+		}
+		return r.print("-->")
+	case UnescapedNode:
+		return ErrSoleChildOnly
+	default:
+		return ErrUnknownNode
+	}
+}
+
+// renderLitExpr prints the expression printed by fn as a template part.
+func (r *renderer) renderLitExpr(fn func() error) error {
+	if err := r.print("${"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return r.print1('}')
+}
+
+func (r *renderer) renderLitText(n *Node) error {
+	if n.Parent != nil && n.Parent.Type != ElementNode {
+		return ErrChildOnly
+	}
+	s := n.Data
+	if n.isRawText() {
+		return r.print(escapeTemplateLiteral(s))
+	}
+	msg := strings.TrimSpace(s)
+	if r.opts.Translator == "" || !r.opts.TranslateText || msg == "" {
+		return r.print(escapeTemplateLiteral(html.EscapeString(s)))
+	}
+	// keep the surrounding space outside of the message
+	i := strings.Index(s, msg)
+	return r.printf("%s${%s(%s)}%s", s[:i], r.opts.Translator, jsString(msg), s[i+len(msg):])
+}
+
+func (r *renderer) renderLitElement(n *Node) error {
+	if n.DataAtom == 0 && (n.Data == "" || n.Data == keyedFragment) {
+		return r.renderLitChildren(n)
+	}
+	if isComponentTag(n) {
+		return r.renderLitExpr(func() error { return r.renderLitComponent(n) })
+	}
+
+	tagName := n.TagName()
+	if err := r.printf("<%s", tagName); err != nil {
+		return err
+	}
+	for _, a := range n.Attr {
+		if err := r.renderLitAttribute(n, a); err != nil {
+			return err
+		}
+	}
+	if err := r.print1('>'); err != nil {
+		return err
+	}
+	if n.DataAtom != 0 {
+		if _, ok := voidElements[n.DataAtom]; ok {
+			if n.FirstChild != nil {
+				return ErrVoidChildren
+			}
+			return nil
+		}
+	}
+
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil {
+		e, err := c.expr()
+		if err != nil {
+			return err
+		}
+		if r.opts.Sanitizer != "" {
+			e = &CallExpr{Func: r.opts.Sanitizer, Args: []Expr{e}}
+		}
+		if err := r.renderLitExpr(func() error {
+			return r.renderExpr(&CallExpr{Func: "unsafeHTML", Args: []Expr{e}}, 0)
+		}); err != nil {
+			return err
+		}
+	} else if err := r.renderLitChildren(n); err != nil {
+		return err
+	}
+	return r.printf("</%s>", tagName)
+}
+
+// renderLitAttribute prints the attribute a of the element n, binding
+// expressions to event listeners, boolean attributes and the properties of
+// form controls as lit does.
+func (r *renderer) renderLitAttribute(n *Node, a Attribute) error {
+	if a.Key == "key" {
+		return nil // ranges key their items with repeat
+	}
+	name := htmlAttrName(n, a)
+	if !a.IsExpr {
+		if a.Val == "" && a.KeyAtom != 0 {
+			if _, ok := boolAttrs[a.KeyAtom]; ok {
+				return r.printf(" %s", name)
+			}
+		}
+		val := a.Val
+		if name == "class" && r.opts.Classes != nil {
+			val = scopedClassNames(val, r.opts.Classes)
+		}
+		return r.printf(` %s="%s"`, name, escapeTemplateLiteral(html.EscapeString(val)))
+	}
+	_, isBool := boolAttrs[a.KeyAtom]
+	switch {
+	case strings.HasPrefix(name, "on") && len(name) > 2:
+		name = "@" + name[2:]
+	case name == "value" || name == "checked":
+		name = "." + name
+	case isBool && a.KeyAtom != 0:
+		name = "?" + name
+	}
+	if err := r.printf(" %s=", name); err != nil {
+		return err
+	}
+	return r.renderLitExpr(func() error { return r.renderParamValue(a, 0) })
+}
+
+// renderLitComponent prints a call of the component n with its attributes
+// and children as props, passing the value of a variable that is the only
+// child as is.
+func (r *renderer) renderLitComponent(n *Node) error {
+	if err := r.printf("%s({", n.Data); err != nil {
+		return err
+	}
+	sep := false
+	for _, a := range n.Attr {
+		if a.Key == "key" {
+			continue
+		}
+		if sep {
+			if err := r.print1(','); err != nil {
+				return err
+			}
+		}
+		if err := r.renderProp(a, attrKey(n, a)); err != nil {
+			return err
+		}
+		sep = true
+	}
+	if n.FirstChild != nil {
+		if sep {
+			if err := r.print1(','); err != nil {
+				return err
+			}
+		}
+		if err := r.print(" children: "); err != nil {
+			return err
+		}
+		if c := n.FirstChild; c == n.LastChild && c.Type == VariableNode {
+			// pass the value, so an empty one is falsy as in React
+			e, err := c.expr()
+			if err != nil {
+				return err
+			}
+			if err := r.renderExpr(e, 0); err != nil {
+				return err
+			}
+		} else {
+			if err := r.print("html`"); err != nil {
+				return err
+			}
+			if err := r.renderLitChildren(n); err != nil {
+				return err
+			}
+			if err := r.print1('`'); err != nil {
+				return err
+			}
+		}
+		sep = true
+	}
+	if sep {
+		if err := r.print1(' '); err != nil {
+			return err
+		}
+	}
+	return r.print("})")
+}
+
+func (r *renderer) renderLitWhen(n *Node) error {
+	if n.FirstChild == nil {
+		return ErrMissingBody
+	}
+	if n.FirstChild != n.LastChild {
+		return ErrTooManyChildren
+	}
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if n.Type == UnlessNode {
+		e = &UnaryExpr{Op: "!", X: e}
+	}
+	if err := r.renderExpr(e, 3); err != nil { // condition of ?:
+		return err
+	}
+	if err := r.print(" ? "); err != nil {
+		return err
+	}
+	if err := r.renderLitTemplate(n.FirstChild); err != nil {
+		return err
+	}
+	return r.print(" : nothing")
+}
+
+func (r *renderer) renderLitRange(n *Node) error {
+	if n.FirstChild == nil {
+		return ErrMissingBody
+	}
+	if n.FirstChild != n.LastChild {
+		return ErrTooManyChildren
+	}
+	e, err := n.expr()
+	if err != nil {
+		return err
+	}
+	if err := r.print("repeat("); err != nil {
+		return err
+	}
+	if r.opts.StrictAccess {
+		err = r.renderExpr(e, 2)
+	} else {
+		err = r.renderExpr(&BinaryExpr{Op: "??", X: e, Y: &LiteralExpr{Kind: ArrayLiteral, Value: "[]"}}, 2)
+	}
+	if err != nil {
+		return err
+	}
+	r.scope++
+	defer func() { r.scope-- }()
+	if err := r.printf(", $%d => $%d.key, $%d => ", r.scope, r.scope, r.scope); err != nil {
+		return err
+	}
+	if err := r.renderLitTemplate(n.FirstChild); err != nil {
+		return err
+	}
+	return r.print1(')')
+}
+
+// renderLitElementClass defines the component n as the custom element
+// named name, a LitElement whose properties are the component's props.
+func (r *renderer) renderLitElementClass(n *Node, name string) error {
+	if n.Data == "" {
+		return ErrUnnamedElement
+	}
+	if err := r.printf("\ncustomElements.define(%s, class extends LitElement {", jsString(name)); err != nil {
+		return err
+	}
+	if props := n.propNames(); len(props) > 0 {
+		if err := r.print("static properties = {"); err != nil {
+			return err
+		}
+		for i, p := range props {
+			if i > 0 {
+				if err := r.print1(','); err != nil {
+					return err
+				}
+			}
+			if err := r.printf(" %s: { attribute: false }", p); err != nil {
+				return err
+			}
+		}
+		if err := r.print(" };"); err != nil {
+			return err
+		}
+	}
+	return r.printf("render() {return %s(this);}});", n.Data)
+}
@@ -41,6 +41,124 @@ func TestRender(t *testing.T) {
 	}
 }
 
+func TestRenderCreateElement(t *testing.T) {
+	const file = "testdata/render_createelement.txt"
+	for _, tc := range buildTestcases(t, file) {
+		t.Run(fmt.Sprintf("%s L%d", file, tc.line), func(t *testing.T) {
+			var sb strings.Builder
+			_, err := restache.RenderWithOptions(&sb, parseNode(t, tc.data), restache.RenderOptions{CreateElement: "React.createElement"})
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := sb.String()
+			want := "export default function ($0) {return " + tc.expected + ";}"
+
+			if got != want {
+				t.Errorf("Render mismatch at line %d:\nwant:\n%s\ngot:\n%s\n", tc.line, want, got)
+			}
+		})
+	}
+}
+
+func TestRenderLit(t *testing.T) {
+	const file = "testdata/render_lit.txt"
+	for _, tc := range buildTestcases(t, file) {
+		t.Run(fmt.Sprintf("%s L%d", file, tc.line), func(t *testing.T) {
+			var sb strings.Builder
+			_, err := restache.RenderWithOptions(&sb, parseNode(t, tc.data), restache.RenderOptions{Target: restache.TargetLit})
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := sb.String()
+			want := "export default function ($0) {return " + tc.expected + ";}"
+
+			if got != want {
+				t.Errorf("Render mismatch at line %d:\nwant:\n%s\ngot:\n%s\n", tc.line, want, got)
+			}
+		})
+	}
+}
+
+func TestRenderStrictAccess(t *testing.T) {
+	for _, tc := range []testCase{
+		{data: "{user.profile.name}", expected: "$0.user.profile.name"},
+		{data: "{#a.b}<i>{c.d}</i>{/a.b}", expected: "$0.a.b.map($1 => <i key={ $1.key }>{$1.c.d}</i>)"},
+		{data: "{#a ?? b}<i></i>{/a}", expected: "($0.a ?? $0.b).map($1 => <i key={ $1.key }></i>)"},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			root, err := restache.Parse(strings.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+
+			var sb strings.Builder
+			_, err = restache.RenderWithOptions(&sb, root, restache.RenderOptions{StrictAccess: true})
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := sb.String()
+			want := "export default function ($0) {return " + tc.expected + ";}"
+
+			if got != want {
+				t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", want, got)
+			}
+		})
+	}
+}
+
+func TestRenderSanitizer(t *testing.T) {
+	root, err := restache.Parse(strings.NewReader("<div>{&body}</div>"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	var sb strings.Builder
+	_, err = restache.RenderWithOptions(&sb, root, restache.RenderOptions{Sanitizer: "clean"})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+
+	want := "export default function ($0) {return <div dangerouslySetInnerHTML={{ __html: clean($0.body) }} />;}"
+	if got := sb.String(); got != want {
+		t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", want, got)
+	}
+}
+
+func TestRenderWhitespace(t *testing.T) {
+	const data = "<p>\n  {#items~}\n  <i>{name}</i>\n  {/items}  tail </p>"
+	for _, tc := range []struct {
+		mode     restache.WhitespaceMode
+		expected string
+	}{
+		{restache.WhitespaceCollapse, "<p>{($0.items ?? []).map($1 => <i key={ $1.key }>{$1.name}</i>)} tail </p>"},
+		{restache.WhitespacePreserve, `<p>{"\n  "}{($0.items ?? []).map($1 => <React.Fragment key={ $1.key }><i>{$1.name}</i>{"\n  "}</React.Fragment>)}{"  tail "}</p>`},
+		{restache.WhitespaceTrim, "<p>{($0.items ?? []).map($1 => <i key={ $1.key }>{$1.name}</i>)}tail</p>"},
+	} {
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			root, err := restache.ParseWithOptions(strings.NewReader(data), restache.ParseOptions{Whitespace: tc.mode})
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+
+			var sb strings.Builder
+			_, err = restache.Render(&sb, root)
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+
+			got := sb.String()
+			want := "export default function ($0) {return " + tc.expected + ";}"
+
+			if got != want {
+				t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", want, got)
+			}
+		})
+	}
+}
+
 type renderErrorCase struct {
 	desc    string
 	node    *restache.Node
@@ -124,6 +242,26 @@ func TestRenderErrors(t *testing.T) {
 			}(),
 			wantErr: restache.ErrChildOnly,
 		},
+		{
+			desc:    "unescaped html at top level",
+			node:    parseNode(t, "{&body}"),
+			wantErr: restache.ErrSoleChildOnly,
+		},
+		{
+			desc:    "unescaped html with siblings",
+			node:    parseNode(t, "<div><h1>title</h1>{&body}</div>"),
+			wantErr: restache.ErrSoleChildOnly,
+		},
+		{
+			desc:    "unescaped html in section",
+			node:    parseNode(t, "<div>{?ok}{&body}{/ok}</div>"),
+			wantErr: restache.ErrSoleChildOnly,
+		},
+		{
+			desc:    "plural form without a translator",
+			node:    parseNode(t, `<p>{t "n" count=n one="1 item" few="{count} items" other="{count} items"}</p>`),
+			wantErr: restache.ErrPluralForm,
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			_, err := restache.Render(io.Discard, tc.node)
@@ -133,3 +271,64 @@ func TestRenderErrors(t *testing.T) {
 		})
 	}
 }
+
+func parseNode(t *testing.T, data string) *restache.Node {
+	t.Helper()
+	root, err := restache.Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	return root
+}
+
+func TestRenderModule(t *testing.T) {
+	const file = "testdata/render_module.txt"
+	for _, tc := range buildTestcases(t, file) {
+		t.Run(fmt.Sprintf("%s L%d", file, tc.line), func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.Render(&sb, parseNode(t, tc.data)); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if got := sb.String(); got != tc.expected {
+				t.Errorf("Render mismatch at line %d:\nwant:\n%s\ngot:\n%s\n", tc.line, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRenderWrappers(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		opts     restache.RenderOptions
+		expected string
+	}{
+		{
+			data: `<p></p><template name="field"><!-- restache:forwardRef --><input ref={@ref}></template>
+<template name="row"><tr></tr></template>`,
+			opts: restache.RenderOptions{Memo: true},
+			expected: "export default React.memo(function ($0) {return <p></p>;})\n" +
+				"export const Field = React.memo(React.forwardRef(function Field($0, $ref) {return <input ref={ $ref } />;}));\n" +
+				"export const Row = React.memo(function Row($0) {return <tr></tr>;});",
+		},
+		{
+			data:     `<template name="row"><tr ref={@ref}></tr></template>`,
+			opts:     restache.RenderOptions{ForwardRef: true},
+			expected: "export const Row = React.forwardRef(function Row($0, $ref) {return <tr ref={ $ref }></tr>;});",
+		},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.RenderWithOptions(&sb, parseNode(t, tc.data), tc.opts); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if got := sb.String(); got != tc.expected {
+				t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", tc.expected, got)
+			}
+		})
+	}
+
+	var sb strings.Builder
+	if _, err := restache.Render(&sb, parseNode(t, "<input ref={@ref}>")); !errors.Is(err, restache.ErrNoRef) {
+		t.Errorf("got error %v, want %v", err, restache.ErrNoRef)
+	}
+}
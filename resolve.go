@@ -0,0 +1,246 @@
+package restache
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ImportSpec is the module a component is imported from.
+type ImportSpec struct {
+	// Path is the module. Unless absolute, it is resolved by esbuild from
+	// the directory of the importing template, like a tag mapping.
+	Path string
+	// Export is the named export of the component, or empty for the
+	// default export.
+	Export string
+}
+
+// A Resolver finds the modules of the components used in templates.
+//
+// Resolve returns the import of the component tag used in the template at
+// importer. An empty ImportSpec, with no error, leaves the tag to the
+// default resolution of tag mappings, tag prefixes and files named after the
+// tag. Resolve may be called concurrently.
+type Resolver interface {
+	Resolve(tag, importer string) (ImportSpec, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(tag, importer string) (ImportSpec, error)
+
+func (f ResolverFunc) Resolve(tag, importer string) (ImportSpec, error) {
+	return f(tag, importer)
+}
+
+// WithResolver resolves component tags with r before the default rules. The
+// resolutions it makes are recorded with cached templates, which are
+// compiled again when r resolves a tag differently.
+func WithResolver(r Resolver) PluginOption {
+	return func(cfg *pluginConfig) {
+		cfg.resolver = r
+	}
+}
+
+// resolveTag returns the import of the component tag used in the template at
+// importer, with the Path resolved.
+func (p *plugin) resolveTag(tag, importer string) (ImportSpec, error) {
+	resolveDir := filepath.Dir(importer)
+	if p.cfg.resolver != nil {
+		spec, err := p.cfg.resolver.Resolve(tag, importer)
+		if err != nil {
+			return ImportSpec{}, err
+		}
+		if spec.Path != "" {
+			if !filepath.IsAbs(spec.Path) {
+				if spec.Path, _, err = p.resolvePath(spec.Path, resolveDir); err != nil {
+					return ImportSpec{}, err
+				}
+			}
+			return spec, nil
+		}
+	}
+	return pluginResolver{p}.Resolve(tag, importer)
+}
+
+// pluginResolver is the default Resolver. It tries the tag mappings, then
+// the directory of the tag's prefix, then files next to the importer named
+// after the tag, in kebab and Pascal case.
+type pluginResolver struct{ p *plugin }
+
+func (r pluginResolver) Resolve(tag, importer string) (ImportSpec, error) {
+	resolveDir := filepath.Dir(importer)
+	if path, ok := r.p.cfg.tagMappings[tag]; ok {
+		if filepath.IsAbs(path) {
+			return ImportSpec{Path: path}, nil
+		}
+		resolved, _, err := r.p.resolvePath(path, resolveDir)
+		if err != nil {
+			return ImportSpec{}, r.p.resolveError(tag, resolveDir, []candidate{{path: path}})
+		}
+		return ImportSpec{Path: resolved}, nil
+	}
+
+	prefix, baseName := tagNameParts(tag)
+	pascal := pascalize(baseName)
+	var candidates []candidate
+	switch basePath, ok := r.p.cfg.tagPrefixes[prefix]; {
+	case prefix == "":
+		candidates = []candidate{
+			{path: currentPath + sanitizeFileName(tag)},
+			{path: currentPath + pascal},
+		}
+	case ok:
+		if !strings.HasSuffix(basePath, fileSep) {
+			basePath += fileSep
+		}
+		candidates = []candidate{
+			{path: basePath + pascal, prefix: prefix},
+			{path: basePath + baseName, prefix: prefix},
+		}
+	default:
+		candidates = []candidate{
+			{path: currentPath + sanitizeFileName(tag)},
+			{path: currentPath + pascalize(prefix) + pascal},
+			{path: currentPath + filepath.Join(prefix, sanitizeFileName(baseName)), prefix: prefix},
+			{path: currentPath + filepath.Join(prefix, pascal), prefix: prefix},
+		}
+	}
+	paths := make([]string, len(candidates))
+	for i, c := range candidates {
+		paths[i] = c.path
+	}
+	resolved, _, err := r.p.resolvePathAny(resolveDir, paths...)
+	if err != nil {
+		return ImportSpec{}, r.p.resolveError(tag, resolveDir, candidates)
+	}
+	return ImportSpec{Path: resolved}, nil
+}
+
+// A candidate is a path a tag may resolve to. Files next to it are named
+// by tags with prefix.
+type candidate struct {
+	path   string
+	prefix string
+}
+
+// resolveError reports a tag that resolved to none of the paths tried.
+type resolveError struct {
+	tag         string
+	tried       []string
+	suggestions []string // tags of similar files
+}
+
+func (e *resolveError) Error() string {
+	tried := make([]string, len(e.tried))
+	for i, path := range e.tried {
+		tried[i] = strconv.Quote(filepath.ToSlash(path))
+	}
+	msg := "cannot resolve <" + e.tag + ">; tried " + strings.Join(tried, ", ")
+	if len(e.suggestions) > 0 {
+		msg += "; did you mean <" + strings.Join(e.suggestions, "> or <") + ">?"
+	}
+	return msg
+}
+
+// maxSuggestions is the number of close matches a resolveError suggests.
+const maxSuggestions = 3
+
+// resolveError returns the error of tag resolving to none of candidates,
+// suggesting the tags of files with similar names in their directories.
+func (p *plugin) resolveError(tag, resolveDir string, candidates []candidate) *resolveError {
+	e := &resolveError{tag: tag}
+	type match struct {
+		tag  string
+		dist int
+	}
+	var (
+		matches []match
+		listed  = make(map[string][]fs.DirEntry)
+	)
+	for _, c := range candidates {
+		e.tried = append(e.tried, c.path)
+		if p.readDir == nil || !filepath.IsAbs(c.path) && !strings.HasPrefix(filepath.ToSlash(c.path), ".") {
+			continue // a package
+		}
+		dir := filepath.Dir(c.path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(resolveDir, dir)
+		}
+		entries, ok := listed[dir]
+		if !ok {
+			entries, _ = p.readDir(dir)
+			listed[dir] = entries
+		}
+		want := fuzzyName(filepath.Base(c.path))
+		for _, entry := range entries {
+			stem, _, _ := strings.Cut(entry.Name(), ".")
+			if stem == "" {
+				continue
+			}
+			name := kebabize(stem)
+			if c.prefix != "" {
+				name = c.prefix + ":" + name
+			}
+			dist := editDistance(want, fuzzyName(stem))
+			if name != tag && dist <= max(1, len(want)/3) {
+				matches = append(matches, match{name, dist})
+			}
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.dist - b.dist })
+	for _, m := range matches {
+		if !slices.Contains(e.suggestions, m.tag) && len(e.suggestions) < maxSuggestions {
+			e.suggestions = append(e.suggestions, m.tag)
+		}
+	}
+	return e
+}
+
+// fuzzyName returns the file name s in lower case without separators, so
+// kebab and Pascal case names compare equal.
+func fuzzyName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// componentIdent returns the local name a component tag is imported as,
+// before it is made unique.
+func (p *plugin) componentIdent(tag string) string {
+	if _, ok := p.cfg.tagMappings[tag]; ok {
+		return pascalize(tag)
+	}
+	prefix, baseName := tagNameParts(tag)
+	if _, ok := p.cfg.tagPrefixes[prefix]; prefix != "" && !ok {
+		return pascalize(prefix) + pascalize(baseName)
+	}
+	return pascalize(baseName)
+}
@@ -0,0 +1,132 @@
+package restache_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestResolver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/page.stache":        {Data: []byte("<main><ds:button>Go</ds:button><ds:icon /><app-logo /><page-footer /></main>")},
+		"app/page-footer.stache": {Data: []byte("<footer></footer>")},
+		"app/bad.stache":         {Data: []byte("<ds:unknown />")},
+		"app/gone.stache":        {Data: []byte("<p>\n  <ds:gone />\n</p>")},
+		"brand/Logo.jsx":         {Data: []byte("export default () => null;")},
+	}
+	errUnknown := errors.New("not in the registry")
+	var (
+		mu        sync.Mutex
+		importers []string
+	)
+	resolver := restache.ResolverFunc(func(tag, importer string) (restache.ImportSpec, error) {
+		mu.Lock()
+		importers = append(importers, importer)
+		mu.Unlock()
+		switch tag {
+		case "ds:button":
+			return restache.ImportSpec{Path: "@acme/ds", Export: "Button"}, nil
+		case "ds:icon":
+			return restache.ImportSpec{Path: "@acme/ds", Export: "Icon"}, nil
+		case "app-logo":
+			return restache.ImportSpec{Path: "../brand/Logo"}, nil
+		case "ds:unknown":
+			return restache.ImportSpec{}, errUnknown
+		case "ds:gone":
+			return restache.ImportSpec{Path: "./removed"}, nil
+		}
+		return restache.ImportSpec{}, nil
+	})
+
+	results, err := restache.CompileAll(fsys, []string{"app/page.stache", "app/bad.stache", "app/gone.stache"}, restache.WithResolver(resolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "import * as React from 'react';\n" +
+		"import AppLogo from '../brand/Logo.jsx';\n" +
+		"import { Button as DsButton } from '@acme/ds';\n" +
+		"import { Icon as DsIcon } from '@acme/ds';\n" +
+		"import PageFooter from './page-footer.stache';\n" +
+		"export default function Page($0) {return <main><DsButton>Go</DsButton><DsIcon></DsIcon><AppLogo></AppLogo><PageFooter></PageFooter></main>;}"
+	if r := results["app/page.stache"]; r.Err != nil || r.Contents != want {
+		t.Errorf("want:\n%s\ngot:\n%s\n%v", want, r.Contents, r.Err)
+	}
+	var serr *restache.SyntaxError
+	if r := results["app/bad.stache"]; !errors.Is(r.Err, errUnknown) || !errors.As(r.Err, &serr) {
+		t.Errorf("bad: got %v, want %v", r.Err, errUnknown)
+	} else if serr.Pos.Line != 1 || serr.Pos.Col != 1 {
+		t.Errorf("bad: got the error at %s, want 1:1", serr.Pos)
+	}
+	if r := results["app/gone.stache"]; !errors.As(r.Err, &serr) || serr.Pos.Line != 2 || serr.Pos.Col != 3 {
+		t.Errorf("gone: expected a syntax error at 2:3, got %v", r.Err)
+	}
+	for _, importer := range importers {
+		if !strings.HasPrefix(importer, "app/") {
+			t.Errorf("unexpected importer %q", importer)
+		}
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	src, cacheDir := t.TempDir(), t.TempDir()
+	entry := filepath.Join(src, "page.stache")
+	writeFile(t, entry, "<my-card></my-card>")
+	writeFile(t, filepath.Join(src, "Plain.jsx"), "export default () => null;")
+	writeFile(t, filepath.Join(src, "Fancy.jsx"), "export default () => null;")
+
+	path := "./Plain"
+	resolver := restache.ResolverFunc(func(tag, importer string) (restache.ImportSpec, error) {
+		return restache.ImportSpec{Path: path}, nil
+	})
+	if out := build(t, entry, restache.WithCacheDir(cacheDir), restache.WithResolver(resolver)); !strings.Contains(out, "Plain.jsx") {
+		t.Fatalf("expected Plain.jsx import, got:\n%s", out)
+	}
+	// The resolver changing its mind invalidates the entry.
+	path = "./Fancy"
+	if out := build(t, entry, restache.WithCacheDir(cacheDir), restache.WithResolver(resolver)); !strings.Contains(out, "Fancy.jsx") {
+		t.Fatalf("expected Fancy.jsx import, got:\n%s", out)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/typo.stache":      {Data: []byte("<main>\n  <user-crad></user-crad>\n</main>")},
+		"app/prefix.stache":    {Data: []byte("<ui:buton></ui:buton>")},
+		"app/missing.stache":   {Data: []byte("<nav-bar></nav-bar>")},
+		"app/shadow.stache":    {Data: []byte("{@props UserCard}\n<p><user-card></user-card></p>")},
+		"app/UserCard.jsx":     {Data: []byte("export default () => null;")},
+		"app/user-list.stache": {Data: []byte("<ul></ul>")},
+		"app/ui/button.jsx":    {Data: []byte("export default () => null;")},
+		"lib/Button/index.jsx": {Data: []byte("export default () => null;")},
+		"lib/lib.stache":       {Data: []byte("<x:butto></x:butto>")},
+	}
+	results, err := restache.CompileAll(fsys, []string{"app", "lib"},
+		restache.WithTagPrefixes(map[string]string{"x": "."}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		file string
+		line int
+		col  int
+		msg  string
+	}{
+		{"app/typo.stache", 2, 3, `cannot resolve <user-crad>; tried "./user-crad", "./UserCrad"; did you mean <user-card>?`},
+		{"app/prefix.stache", 1, 1, `cannot resolve <ui:buton>; tried "./ui-buton", "./UiButon", "./ui/buton", "./ui/Buton"; did you mean <ui:button>?`},
+		{"app/missing.stache", 1, 1, `cannot resolve <nav-bar>; tried "./nav-bar", "./NavBar"`},
+		{"app/shadow.stache", 2, 4, `prop UserCard hides the component of <user-card>`},
+		{"lib/lib.stache", 1, 1, `cannot resolve <x:butto>; tried "./Butto", "./butto"; did you mean <x:button>?`},
+	} {
+		var serr *restache.SyntaxError
+		if err := results[tc.file].Err; !errors.As(err, &serr) {
+			t.Errorf("%s: expected a syntax error, got %v", tc.file, err)
+		} else if serr.Pos.Line != tc.line || serr.Pos.Col != tc.col || serr.Msg != tc.msg {
+			t.Errorf("%s: expected %d:%d: %s\ngot %d:%d: %s", tc.file, tc.line, tc.col, tc.msg, serr.Pos.Line, serr.Pos.Col, serr.Msg)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package restache
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// A JSXRuntime describes how compiled templates create elements. The plugin
+// imports what the runtime needs and sets esbuild's JSX options for each
+// template with pragma comments.
+type JSXRuntime struct {
+	// Automatic selects the automatic runtime, imported by esbuild from
+	// ImportSource + "/jsx-runtime". Otherwise the classic runtime calls
+	// Factory to create elements, and uses Fragment for fragments.
+	Automatic bool
+
+	// ImportSource is the module providing the runtime. The classic runtime
+	// imports the roots of Factory and Fragment from it, as a namespace if
+	// they are dotted; if empty, they are expected to be globals.
+	ImportSource string
+
+	// Factory and Fragment name the element factory and the fragment
+	// component of the classic runtime. They default to React's.
+	Factory  string
+	Fragment string
+
+	// Compat is the module exporting memo and forwardRef, if not
+	// ImportSource. Without either, they come with the namespace of a dotted
+	// Factory.
+	Compat string
+}
+
+// ErrNoCompat is returned for templates wrapped in memo or forwardRef with a
+// runtime that does not say where to import them from.
+var ErrNoCompat = errors.New("memo and forwardRef need a module; set JSXRuntime.Compat")
+
+var (
+	// ReactRuntime is React's classic runtime, used by default.
+	ReactRuntime = JSXRuntime{ImportSource: "react", Factory: "React.createElement", Fragment: "React.Fragment"}
+
+	// ReactAutomaticRuntime is React's automatic runtime.
+	ReactAutomaticRuntime = JSXRuntime{Automatic: true, ImportSource: "react"}
+
+	// PreactRuntime is Preact's classic runtime.
+	PreactRuntime = JSXRuntime{ImportSource: "preact", Factory: "h", Fragment: "Fragment", Compat: "preact/compat"}
+)
+
+// Local names of the runtime functions imported by name.
+const (
+	createElementIdent = "$createElement"
+	fragmentIdent      = "$Fragment"
+	memoIdent          = "$memo"
+	forwardRefIdent    = "$forwardRef"
+)
+
+// keyedFragment is the tag of the fragments that wrap the items of a range.
+const keyedFragment = "React.Fragment"
+
+// header returns the pragma comments and imports that set up rt for the
+// component root, and sets the names opts renders its elements, fragments
+// and wrappers with. Pragmas are left out if opts creates elements without
+// JSX.
+func (rt JSXRuntime) header(root *Node, opts *RenderOptions) (string, error) {
+	var b strings.Builder
+	if rt.Automatic && opts.CreateElement != "" {
+		// the automatic runtime has no factory to call; use the classic one
+		opts.CreateElement, opts.Fragment = createElementIdent, fragmentIdent
+		b.WriteString(importStmt("{ createElement as "+createElementIdent+", Fragment as "+fragmentIdent+" }", cmp.Or(rt.ImportSource, "react")))
+	} else if rt.Automatic {
+		b.WriteString("/** @jsxRuntime automatic */\n")
+		if rt.ImportSource != "" {
+			b.WriteString("/** @jsxImportSource " + rt.ImportSource + " */\n")
+		}
+		if root.hasKeyedFragment() {
+			opts.Fragment = fragmentIdent
+			b.WriteString(importStmt("{ Fragment as "+fragmentIdent+" }", cmp.Or(rt.ImportSource, "react")+"/jsx-runtime"))
+		}
+	} else {
+		factory, fragment := cmp.Or(rt.Factory, ReactRuntime.Factory), cmp.Or(rt.Fragment, ReactRuntime.Fragment)
+		if opts.CreateElement != "" {
+			opts.CreateElement = factory
+		} else {
+			b.WriteString("/** @jsxRuntime classic */\n")
+			b.WriteString("/** @jsx " + factory + " */\n")
+			b.WriteString("/** @jsxFrag " + fragment + " */\n")
+		}
+		opts.Fragment = fragment
+		if rt.ImportSource != "" {
+			var namespaces, named []string
+			for _, name := range []string{factory, fragment} {
+				ns, _, dotted := strings.Cut(name, ".")
+				switch {
+				case dotted && !slices.Contains(namespaces, ns):
+					namespaces = append(namespaces, ns)
+					b.WriteString(importStmt("* as "+ns, rt.ImportSource))
+				case !dotted && !slices.Contains(named, name):
+					named = append(named, name)
+				}
+			}
+			if len(named) > 0 {
+				b.WriteString(importStmt("{ "+strings.Join(named, ", ")+" }", rt.ImportSource))
+			}
+		}
+		if ns, _, dotted := strings.Cut(factory, "."); dotted && rt.Compat == "" {
+			// wrappers come with the namespace
+			opts.MemoFunc, opts.ForwardRefFunc = ns+".memo", ns+".forwardRef"
+			return b.String(), nil
+		}
+	}
+	module := cmp.Or(rt.Compat, rt.ImportSource)
+	if rt.Automatic {
+		module = cmp.Or(module, "react")
+	}
+	var named []string
+	if memo, ref := root.wrappers(opts); memo || ref {
+		if module == "" {
+			return "", ErrNoCompat
+		}
+		if memo {
+			named = append(named, "memo as "+memoIdent)
+			opts.MemoFunc = memoIdent
+		}
+		if ref {
+			named = append(named, "forwardRef as "+forwardRefIdent)
+			opts.ForwardRefFunc = forwardRefIdent
+		}
+		b.WriteString(importStmt("{ "+strings.Join(named, ", ")+" }", module))
+	}
+	return b.String(), nil
+}
+
+func importStmt(clause, path string) string {
+	return "import " + clause + " from '" + path + "';\n"
+}
+
+// hasKeyedFragment reports whether n or any of its descendants is a keyed
+// fragment.
+func (n *Node) hasKeyedFragment() bool {
+	if n.Type == ElementNode && n.Data == keyedFragment {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.hasKeyedFragment() {
+			return true
+		}
+	}
+	return false
+}
+
+// wrappers reports whether the components of the file n are wrapped in memo
+// and forwardRef when rendered with opts.
+func (n *Node) wrappers(opts *RenderOptions) (memo, ref bool) {
+	memo, ref = opts.Memo, opts.ForwardRef
+	for _, c := range append([]*Node{n}, n.components()...) {
+		memo = memo || c.Memo != nil
+		ref = ref || c.ForwardRef
+	}
+	return memo, ref
+}
+
+// components returns the named components declared in the file n.
+func (n *Node) components() []*Node {
+	var comps []*Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == ComponentNode {
+			comps = append(comps, c)
+		}
+	}
+	return comps
+}
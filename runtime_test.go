@@ -0,0 +1,190 @@
+package restache_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestCompileJSXRuntime(t *testing.T) {
+	fsys := fstest.MapFS{
+		"list.stache":  {Data: []byte("<!-- restache:memo --><ul>{#items}<li></li><li></li>{/items}</ul>")},
+		"empty.stache": {Data: []byte("")},
+	}
+	for _, tc := range []struct {
+		name     string
+		rt       restache.JSXRuntime
+		expected string
+		err      error
+	}{
+		{
+			name: "react",
+			rt:   restache.ReactRuntime,
+			expected: "/** @jsxRuntime classic */\n" +
+				"/** @jsx React.createElement */\n" +
+				"/** @jsxFrag React.Fragment */\n" +
+				"import * as React from 'react';\n" +
+				"export default React.memo(function List($0) {return <ul>{($0.items ?? []).map($1 => <React.Fragment key={ $1.key }><li></li><li></li></React.Fragment>)}</ul>;})",
+		},
+		{
+			name: "automatic",
+			rt:   restache.ReactAutomaticRuntime,
+			expected: "/** @jsxRuntime automatic */\n" +
+				"/** @jsxImportSource react */\n" +
+				"import { Fragment as $Fragment } from 'react/jsx-runtime';\n" +
+				"import { memo as $memo } from 'react';\n" +
+				"export default $memo(function List($0) {return <ul>{($0.items ?? []).map($1 => <$Fragment key={ $1.key }><li></li><li></li></$Fragment>)}</ul>;})",
+		},
+		{
+			name: "preact",
+			rt:   restache.PreactRuntime,
+			expected: "/** @jsxRuntime classic */\n" +
+				"/** @jsx h */\n" +
+				"/** @jsxFrag Fragment */\n" +
+				"import { h, Fragment } from 'preact';\n" +
+				"import { memo as $memo } from 'preact/compat';\n" +
+				"export default $memo(function List($0) {return <ul>{($0.items ?? []).map($1 => <Fragment key={ $1.key }><li></li><li></li></Fragment>)}</ul>;})",
+		},
+		{
+			name: "globals",
+			rt:   restache.JSXRuntime{Factory: "m", Fragment: "m.Fragment"},
+			err:  restache.ErrNoCompat,
+		},
+		{
+			name: "globals with compat",
+			rt:   restache.JSXRuntime{Factory: "m", Fragment: "m.Fragment", Compat: "m-compat"},
+			expected: "/** @jsxRuntime classic */\n" +
+				"/** @jsx m */\n" +
+				"/** @jsxFrag m.Fragment */\n" +
+				"import { memo as $memo } from 'm-compat';\n" +
+				"export default $memo(function List($0) {return <ul>{($0.items ?? []).map($1 => <m.Fragment key={ $1.key }><li></li><li></li></m.Fragment>)}</ul>;})",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := restache.CompileAll(fsys, []string{"*.stache"}, restache.WithJSXRuntime(tc.rt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := results["list.stache"]; tc.err != nil {
+				if !errors.Is(got.Err, tc.err) {
+					t.Errorf("list.stache: expected %v, got %v", tc.err, got.Err)
+				}
+			} else if got.Err != nil || got.Contents != tc.expected {
+				t.Errorf("list.stache mismatch (err %v):\nwant:\n%s\ngot:\n%s", got.Err, tc.expected, got.Contents)
+			}
+			if got := results["empty.stache"].Contents; strings.Contains(got, "memo") || !strings.HasSuffix(got, "export default function Empty($0) {return <></>;}") {
+				t.Errorf("empty.stache: unexpected contents:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestPluginJSXRuntime(t *testing.T) {
+	entry := filepath.Join(t.TempDir(), "greeting.stache")
+	writeFile(t, entry, "<p>Hello</p>")
+
+	out := build(t, entry, restache.WithJSXRuntime(restache.ReactAutomaticRuntime))
+	if !strings.Contains(out, `from "react/jsx-runtime"`) || strings.Contains(out, "React.createElement") {
+		t.Errorf("expected the automatic runtime, got:\n%s", out)
+	}
+
+	out = build(t, entry, restache.WithJSXRuntime(restache.PreactRuntime))
+	if !strings.Contains(out, `h("p", null, "Hello")`) {
+		t.Errorf("expected preact's factory, got:\n%s", out)
+	}
+}
+
+func TestCompileCreateElement(t *testing.T) {
+	fsys := fstest.MapFS{
+		"list.stache": {Data: []byte("<ul>{#items}<li>{name}</li>{/items}</ul>")},
+	}
+	for _, tc := range []struct {
+		name     string
+		opts     []restache.PluginOption
+		expected string
+	}{
+		{
+			name: "react",
+			expected: "import * as React from 'react';\n" +
+				"export default function List($0) {return React.createElement(\"ul\", null, ($0.items ?? []).map($1 => React.createElement(\"li\", { key: $1.key }, $1.name)));}",
+		},
+		{
+			name: "automatic",
+			opts: []restache.PluginOption{restache.WithJSXRuntime(restache.ReactAutomaticRuntime)},
+			expected: "import { createElement as $createElement, Fragment as $Fragment } from 'react';\n" +
+				"export default function List($0) {return $createElement(\"ul\", null, ($0.items ?? []).map($1 => $createElement(\"li\", { key: $1.key }, $1.name)));}",
+		},
+		{
+			name: "preact",
+			opts: []restache.PluginOption{restache.WithJSXRuntime(restache.PreactRuntime)},
+			expected: "import { h, Fragment } from 'preact';\n" +
+				"export default function List($0) {return h(\"ul\", null, ($0.items ?? []).map($1 => h(\"li\", { key: $1.key }, $1.name)));}",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := restache.CompileAll(fsys, []string{"*.stache"}, append(tc.opts, restache.WithCreateElement())...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := results["list.stache"]; got.Err != nil || got.Contents != tc.expected {
+				t.Errorf("list.stache mismatch (err %v):\nwant:\n%s\ngot:\n%s", got.Err, tc.expected, got.Contents)
+			}
+		})
+	}
+}
+
+func TestPluginCreateElement(t *testing.T) {
+	entry := filepath.Join(t.TempDir(), "greeting.stache")
+	writeFile(t, entry, "<p class=\"hi\">Hello, {name}</p>")
+
+	out := build(t, entry, restache.WithCreateElement())
+	if !strings.Contains(out, `React.createElement("p", { className: "hi" }, "Hello, ", $0.name)`) {
+		t.Errorf("expected createElement calls, got:\n%s", out)
+	}
+}
+
+func TestRunReact(t *testing.T) {
+	files := map[string]string{
+		"page.stache": `<!-- restache:memo -->
+{@props title="Cart" items=[]}
+<h1 class="title">{title}</h1>
+<ul>{#items}<cart-item key={id} name={name} qty={qty}>{note}</cart-item>{/items}</ul>
+{^items.length}<p>Empty</p>{/items.length}
+<p>{t "total" count=items.length one="{count} item" other="{count} items"} <price amount={total} /></p>
+<label for="q">Search</label><input id="q" value={query} disabled={busy}>
+<div>{&raw}</div>
+<!-- restache:customElements sl-* -->
+<sl-badge variant="primary" class="b">{title}</sl-badge>
+<template name="price"><b>{amount}</b></template>`,
+		"cart-item.stache": `<li class="{qty > 1 ? 'bulk' : ''}">{name} × {qty}{?children} <i>{children}</i>{/children}</li>`,
+		"main.js": `import { createElement, renderToString } from 'react';
+import Page from './page.stache';
+console.log(renderToString(createElement(Page, {
+  items: [{ id: 1, name: "Tea <green>", qty: 2, note: "wrap & ship" }, { id: 2, name: "Cup", qty: 1 }],
+  total: 3, query: "q&a", busy: true, raw: "<em>hi</em>",
+})));
+console.log(renderToString(createElement(Page, { title: "Basket" })));
+`,
+	}
+	const want = `<h1 class="title">Cart</h1><ul><li class="bulk">Tea &lt;green&gt; × 2 <i>wrap &amp; ship</i></li><li class="">Cup × 1</li></ul>` +
+		`<p>2 items <b>3</b></p><label for="q">Search</label><input id="q" value="q&amp;a" disabled><div><em>hi</em></div><sl-badge variant="primary" class="b">Cart</sl-badge>` + "\n" +
+		`<h1 class="title">Basket</h1><ul></ul><p>Empty</p><p>0 items <b></b></p><label for="q">Search</label><input id="q"><div></div><sl-badge variant="primary" class="b">Basket</sl-badge>`
+	for _, tc := range []struct {
+		name string
+		opts []restache.PluginOption
+	}{
+		{"classic", nil},
+		{"automatic", []restache.PluginOption{restache.WithJSXRuntime(restache.ReactAutomaticRuntime)}},
+		{"createElement", []restache.PluginOption{restache.WithCreateElement()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runJS(t, files, tc.opts...); got != want {
+				t.Errorf("want:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
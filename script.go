@@ -0,0 +1,220 @@
+package restache
+
+import (
+	"strings"
+)
+
+// A jsToken is a token of the JavaScript in a component script. Scanning is
+// shallow: it knows identifiers, punctuation, strings and comments, which is
+// enough to find imports and top-level declarations.
+type jsToken struct {
+	val        string // identifier, punctuation or string literal
+	str        bool   // val is a string literal
+	start, end int
+}
+
+func jsTokens(src string) []jsToken {
+	var toks []jsToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case spaceTable[c]:
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			if j := strings.IndexByte(src[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(src)
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			if j := strings.Index(src[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(src)
+			}
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(src))
+			toks = append(toks, jsToken{val: src[i:j], str: true, start: i, end: j})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && isIdentPart(src[j]) {
+				j++
+			}
+			toks = append(toks, jsToken{val: src[i:j], start: i, end: j})
+			i = j
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, jsToken{val: "...", start: i, end: i + 3})
+			i += 3
+		default:
+			toks = append(toks, jsToken{val: src[i : i+1], start: i, end: i + 1})
+			i++
+		}
+	}
+	return toks
+}
+
+// A componentScript is the script block of a component, split into the
+// import declarations hoisted to the module and the statements run by the
+// component function.
+type componentScript struct {
+	imports []string
+	body    string
+	names   []string // identifiers declared at the top level
+}
+
+func parseScript(src string) *componentScript {
+	var (
+		s     = &componentScript{}
+		toks  = jsTokens(src)
+		depth int
+		body  strings.Builder
+		last  int // end of the last import in src
+	)
+	for i := 0; i < len(toks); i++ {
+		switch toks[i].val {
+		case "(", "[", "{":
+			depth++
+			continue
+		case ")", "]", "}":
+			depth--
+			continue
+		}
+		if depth != 0 || toks[i].str {
+			continue
+		}
+		switch toks[i].val {
+		case "import":
+			if i+1 < len(toks) && (toks[i+1].val == "(" || toks[i+1].val == ".") {
+				continue // dynamic import or import.meta
+			}
+			end := s.importDecl(toks, i+1)
+			body.WriteString(src[last:toks[i].start])
+			s.imports = append(s.imports, src[toks[i].start:toks[end-1].end])
+			last = toks[end-1].end
+			i = end - 1
+		case "const", "let", "var":
+			i = s.declarators(toks, i+1) - 1
+		case "function", "class":
+			j := i + 1
+			if j < len(toks) && toks[j].val == "*" {
+				j++
+			}
+			if j < len(toks) && isIdent(toks[j].val) {
+				s.names = append(s.names, toks[j].val)
+			}
+		}
+	}
+	body.WriteString(src[last:])
+	s.body = strings.TrimSpace(body.String())
+	return s
+}
+
+// importDecl records the bindings of the import declaration whose clause
+// starts at toks[i], and returns the index following it.
+func (s *componentScript) importDecl(toks []jsToken, i int) int {
+	for ; i < len(toks) && !toks[i].str; i++ {
+		switch v := toks[i].val; {
+		case v == "as":
+			i++
+			if i < len(toks) {
+				s.names = append(s.names, toks[i].val)
+			}
+		case isIdent(v) && v != "from" && v != "type" && (i+1 >= len(toks) || toks[i+1].val != "as"):
+			s.names = append(s.names, v)
+		}
+	}
+	i++ // module specifier
+	if i < len(toks) && (toks[i].val == "with" || toks[i].val == "assert") {
+		i = skipBalanced(toks, i+1)
+	}
+	if i < len(toks) && toks[i].val == ";" {
+		i++
+	}
+	return min(i, len(toks))
+}
+
+// declarators records the names bound by the declarators of a variable
+// declaration starting at toks[i], and returns the index following them.
+func (s *componentScript) declarators(toks []jsToken, i int) int {
+	for i < len(toks) {
+		switch v := toks[i].val; {
+		case v == "[" || v == "{":
+			end := skipBalanced(toks, i)
+			s.pattern(toks[i+1 : end-1])
+			i = end
+		case isIdent(v):
+			s.names = append(s.names, v)
+			i++
+		default:
+			return i
+		}
+		if i < len(toks) && toks[i].val == "=" {
+			i = skipInitializer(toks, i+1)
+		}
+		if i >= len(toks) || toks[i].val != "," {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// pattern records the names bound by the elements of a destructuring
+// pattern.
+func (s *componentScript) pattern(toks []jsToken) {
+	for i := 0; i < len(toks); i++ {
+		switch v := toks[i].val; {
+		case v == "=":
+			// skip the default value
+			for i+1 < len(toks) && toks[i+1].val != "," {
+				if o := toks[i+1].val; o == "(" || o == "[" || o == "{" {
+					i = skipBalanced(toks, i+1) - 2
+				}
+				i++
+			}
+		case isIdent(v) && !toks[i].str && (i+1 >= len(toks) || toks[i+1].val != ":"):
+			s.names = append(s.names, v)
+		}
+	}
+}
+
+// skipBalanced returns the index following the bracketed group that starts
+// at toks[i].
+func skipBalanced(toks []jsToken, i int) int {
+	depth := 0
+	for ; i < len(toks); i++ {
+		switch toks[i].val {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// skipInitializer returns the index of the ',' or ';' that ends the
+// initializer starting at toks[i], or of the next declaration.
+func skipInitializer(toks []jsToken, i int) int {
+	for i < len(toks) {
+		switch toks[i].val {
+		case "(", "[", "{":
+			i = skipBalanced(toks, i)
+			continue
+		case ",", ";", "const", "let", "var", "function", "class", "import":
+			return i
+		}
+		i++
+	}
+	return i
+}
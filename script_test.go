@@ -0,0 +1,83 @@
+package restache_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestRenderScript(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		data     string
+		expected string
+	}{
+		{
+			desc: "hooks and helpers",
+			data: `<script>
+  import { useState } from 'react';
+  import format, * as dates from "./dates.js"
+  import './styles.css';
+
+  const [open, setOpen] = useState(false);
+  const { a: label = "x", ...rest } = $0, max = Math.max(1, 2);
+  function toggle() { setOpen(!open); }
+  const data = import.meta.env; // not an import
+</script>
+{@props title}
+<button onClick={toggle} aria-expanded={open}>{title} {label} {format(date)}</button>
+{?open}<ul>{#items}<li>{rest.x} {name}</li>{/items}</ul>{/open}`,
+			expected: "import { useState } from 'react';\n" +
+				"import format, * as dates from \"./dates.js\"\n" +
+				"import './styles.css';\n" +
+				"export default function ($0) {const { title } = $0;\n" +
+				"const [open, setOpen] = useState(false);\n" +
+				"  const { a: label = \"x\", ...rest } = $0, max = Math.max(1, 2);\n" +
+				"  function toggle() { setOpen(!open); }\n" +
+				"  const data = import.meta.env; // not an import\n" +
+				"return <><button onClick={ toggle } aria-expanded={ open }>{title}{\" \"}{label}{\" \"}{format($0.date)}</button>" +
+				"{(open && <ul>{($0.items ?? []).map($1 => <li key={ $1.key }>{$1.rest?.x}{\" \"}{$1.name}</li>)}</ul>)}</>;}",
+		},
+		{
+			desc:     "names in a range are fields of the item",
+			data:     `<script>const name = "x";</script><p>{name}</p>{#items}<p>{name}</p>{/items}`,
+			expected: "export default function ($0) {\nconst name = \"x\";\nreturn <><p>{name}</p>{($0.items ?? []).map($1 => <p key={ $1.key }>{$1.name}</p>)}</>;}",
+		},
+		{
+			desc: "module script without a default component",
+			data: `<script>const fmt = (n) => n.toFixed(2);</script>
+<template name="price"><b>{fmt(value)}</b></template>`,
+			expected: "const fmt = (n) => n.toFixed(2);\n" +
+				"export function Price($0) {return <b>{fmt($0.value)}</b>;}",
+		},
+		{
+			desc:     "script after content is an element",
+			data:     `<p></p><script>const x = {};</script>`,
+			expected: "export default function ($0) {return <><p></p><script>{`const x = {};`}</script></>;}",
+		},
+		{
+			desc:     "script with attributes is an element",
+			data:     `<script type="module">go()</script>`,
+			expected: "export default function ($0) {return <script type=\"module\">{`go()`}</script>;}",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.Render(&sb, parseNode(t, tc.data)); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if got := sb.String(); got != tc.expected {
+				t.Errorf("Render mismatch:\nwant:\n%s\ngot:\n%s\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestUndeclaredPropsScript(t *testing.T) {
+	root := parseNode(t, "<script>const [n, setN] = useState(0);</script>{@props}<b>{n} {m}</b>")
+	errs := restache.UndeclaredProps(root)
+	if len(errs) != 1 || errs[0].Msg != `undeclared prop "m"` {
+		t.Errorf("got %v, want only m undeclared", errs)
+	}
+}
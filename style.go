@@ -0,0 +1,119 @@
+package restache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// styleNamespace is the esbuild namespace of the styles extracted from
+// templates. A template imports its styles as "restache-style:<file name>".
+const styleNamespace = "restache-style"
+
+// classSuffix returns the suffix appended to the class names of the styles
+// css of the template named name.
+func classSuffix(name, css string) string {
+	h := sha256.Sum256([]byte(name + "\x00" + css))
+	return hex.EncodeToString(h[:3])
+}
+
+// scopeClasses renames the classes in the selectors of the stylesheet css by
+// appending suffix, records each name in classes, and returns the result.
+// Names already in classes keep their recorded name. Declarations and the
+// preludes of at-rules are left as they are.
+func scopeClasses(css, suffix string, classes map[string]string) string {
+	var b strings.Builder
+	start := 0 // of the current prelude or declaration
+	for i := 0; i < len(css); i++ {
+		if j := skipCSSLiteral(css, i); j > i {
+			i = j - 1
+			continue
+		}
+		switch css[i] {
+		case '{':
+			prelude := css[start:i]
+			if !strings.HasPrefix(strings.TrimSpace(prelude), "@") {
+				prelude = scopeSelector(prelude, suffix, classes)
+			}
+			b.WriteString(prelude)
+			b.WriteByte('{')
+			start = i + 1
+		case ';', '}':
+			b.WriteString(css[start : i+1])
+			start = i + 1
+		}
+	}
+	b.WriteString(css[start:])
+	return b.String()
+}
+
+// scopeSelector renames the classes in the selector list s.
+func scopeSelector(s, suffix string, classes map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if j := skipCSSLiteral(s, i); j > i {
+			b.WriteString(s[i:j])
+			i = j - 1
+			continue
+		}
+		if s[i] != '.' || i+1 == len(s) || !isCSSIdentStart(s[i+1]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		j := i + 1
+		for j < len(s) && isCSSIdentPart(s[j]) {
+			j++
+		}
+		name := s[i+1 : j]
+		scoped, ok := classes[name]
+		if !ok {
+			scoped = name + "_" + suffix
+			classes[name] = scoped
+		}
+		b.WriteByte('.')
+		b.WriteString(scoped)
+		i = j - 1
+	}
+	return b.String()
+}
+
+// skipCSSLiteral returns the index following the string or comment that
+// starts at s[i], or i if there is none.
+func skipCSSLiteral(s string, i int) int {
+	switch {
+	case s[i] == '"' || s[i] == '\'':
+		j := i + 1
+		for j < len(s) && s[j] != s[i] {
+			if s[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		return min(j+1, len(s))
+	case strings.HasPrefix(s[i:], "/*"):
+		if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+			return i + j + 4
+		}
+		return len(s)
+	}
+	return i
+}
+
+func isCSSIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '-' || c >= 0x80
+}
+
+func isCSSIdentPart(c byte) bool {
+	return isCSSIdentStart(c) || '0' <= c && c <= '9'
+}
+
+// scopedClassNames maps the names in the class list s through classes.
+func scopedClassNames(s string, classes map[string]string) string {
+	names := strings.Fields(s)
+	for i, name := range names {
+		if scoped, ok := classes[name]; ok {
+			names[i] = scoped
+		}
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,188 @@
+package restache_test
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/tetsuo/restache"
+)
+
+func TestCompileStyles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"card.stache": {Data: []byte(`<div class="card  wide" id="x"><b class="title other">{title}</b><i class={cls}></i></div>
+<style scoped>
+  /* .skip { } */
+  .card > .title:hover, a[href$=".pdf"] { background: url(bg.png); }
+  @media (min-width: 1.5em) { .card { width: 100%; } }
+  .wide{}
+</style>`)},
+		"card.module.css":   {Data: []byte(".title { color: red; }\n")},
+		"plain.stache":      {Data: []byte(`<p class="title"></p>`)},
+		"twice.stache":      {Data: []byte(`<style scoped></style><style scoped></style>`)},
+		"unscoped.stache":   {Data: []byte(`<style>.a{}</style>`)},
+		"module.stache":     {Data: []byte(`<p class="x"></p>`)},
+		"module.module.css": {Data: []byte(".x{}")},
+	}
+
+	results, err := restache.CompileAll(fsys, []string{"*.stache"}, restache.WithCSSModules())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := results["card.stache"]
+	if card.Err != nil {
+		t.Fatal(card.Err)
+	}
+	suffix := regexp.MustCompile(`title_([0-9a-f]{6})`).FindStringSubmatch(card.Styles)
+	if suffix == nil {
+		t.Fatalf("no scoped title class in:\n%s", card.Styles)
+	}
+	s := suffix[1]
+	wantStyles := ".title_" + s + " { color: red; }\n\n\n" +
+		"  /* .skip { } */\n" +
+		"  .card_" + s + " > .title_" + s + `:hover, a[href$=".pdf"] { background: url(bg.png); }` + "\n" +
+		"  @media (min-width: 1.5em) { .card_" + s + " { width: 100%; } }\n" +
+		"  .wide_" + s + "{}\n"
+	if card.Styles != wantStyles {
+		t.Errorf("styles mismatch:\nwant:\n%s\ngot:\n%s", wantStyles, card.Styles)
+	}
+	wantContents := "import * as React from 'react';\n" +
+		"export default function Card($0) {return <div className=\"card_" + s + " wide_" + s + "\" id=\"x\">" +
+		"<b className=\"title_" + s + " other\">{$0.title}</b><i className={ $0.cls }></i></div>;}"
+	if card.Contents != wantContents {
+		t.Errorf("contents mismatch:\nwant:\n%s\ngot:\n%s", wantContents, card.Contents)
+	}
+
+	if r := results["plain.stache"]; r.Styles != "" || !strings.Contains(r.Contents, `className="title"`) {
+		t.Errorf("plain.stache: unexpected styles %q or contents:\n%s", r.Styles, r.Contents)
+	}
+	if r := results["unscoped.stache"]; r.Styles != "" || !strings.Contains(r.Contents, "<style>") {
+		t.Errorf("unscoped.stache: unexpected styles %q or contents:\n%s", r.Styles, r.Contents)
+	}
+	if r := results["module.stache"]; !strings.HasPrefix(r.Styles, ".x_") || !strings.Contains(r.Contents, `className="x_`) {
+		t.Errorf("module.stache: unexpected styles %q or contents:\n%s", r.Styles, r.Contents)
+	}
+	if err := results["twice.stache"].Err; err == nil || !strings.Contains(err.Error(), "duplicate scoped style") {
+		t.Errorf("twice.stache: got error %v, want duplicate scoped style", err)
+	}
+}
+
+func TestPluginStyles(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "badge.stache")
+	writeFile(t, entry, `<span class="badge">{label}</span><style scoped>.badge { color: red; }</style>`)
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Plugins:     []api.Plugin{restache.Plugin()},
+		Format:      api.FormatESModule,
+		Bundle:      true,
+		External:    []string{"react"},
+		Outdir:      filepath.Join(dir, "out"),
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors)
+	}
+
+	var js, css string
+	for _, f := range result.OutputFiles {
+		switch filepath.Ext(f.Path) {
+		case ".js":
+			js = string(f.Contents)
+		case ".css":
+			css = string(f.Contents)
+		}
+	}
+	m := regexp.MustCompile(`\.(badge_[0-9a-f]{6}) \{`).FindStringSubmatch(css)
+	if m == nil {
+		t.Fatalf("no scoped class in css:\n%s", css)
+	}
+	if !strings.Contains(js, `className: "`+m[1]+`"`) {
+		t.Errorf("expected className %q in js:\n%s", m[1], js)
+	}
+}
+
+func TestPluginWatchCSSModule(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	// the second build starts from the entry cached by the first
+	for _, name := range []string{"compiled", "cached"} {
+		t.Run(name, func(t *testing.T) {
+			watchCSSModule(t, dir, cacheDir)
+		})
+	}
+}
+
+func watchCSSModule(t *testing.T, dir, cacheDir string) {
+	entry := filepath.Join(dir, "badge.stache")
+	module := filepath.Join(dir, "badge.module.css")
+	writeFile(t, entry, `<span class="badge">{label}</span>`)
+	writeFile(t, module, ".badge { color: red; }")
+
+	builds := make(chan api.BuildResult, 4)
+	ctx, cerr := api.Context(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Plugins: []api.Plugin{restache.Plugin(restache.WithCSSModules(), restache.WithCacheDir(cacheDir)), {
+			Name: "builds",
+			Setup: func(build api.PluginBuild) {
+				build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+					builds <- *result
+					return api.OnEndResult{}, nil
+				})
+			},
+		}},
+		Format:   api.FormatESModule,
+		Bundle:   true,
+		External: []string{"react"},
+		Outdir:   filepath.Join(dir, "out"),
+		Write:    false,
+	})
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+	defer ctx.Dispose()
+
+	// class returns the scoped badge class in the css and the js of the
+	// next build.
+	class := func() (string, string) {
+		t.Helper()
+		var result api.BuildResult
+		select {
+		case result = <-builds:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no rebuild after editing the CSS module")
+		}
+		if len(result.Errors) > 0 {
+			t.Fatalf("build errors: %v", result.Errors)
+		}
+		var js, css string
+		for _, f := range result.OutputFiles {
+			switch filepath.Ext(f.Path) {
+			case ".js":
+				js = string(f.Contents)
+			case ".css":
+				css = string(f.Contents)
+			}
+		}
+		m := regexp.MustCompile(`\.(badge_[0-9a-f]{6}) \{`).FindStringSubmatch(css)
+		if m == nil {
+			t.Fatalf("no scoped class in css:\n%s", css)
+		}
+		return m[1], regexp.MustCompile(`badge_[0-9a-f]{6}`).FindString(js)
+	}
+
+	if err := ctx.Watch(api.WatchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := class()
+	// only the CSS module changes
+	writeFile(t, module, ".badge { color: blue; }")
+	after, js := class()
+	if after == before || js != after {
+		t.Errorf("got class %s in css and %s in js after editing the CSS module, was %s", after, js, before)
+	}
+}
@@ -353,3 +353,63 @@ var boolAttrs = map[atom.Atom]struct{}{
 var spaceTable = [256]bool{
 	' ': true, '\t': true, '\r': true, '\n': true,
 }
+
+// nativeElements maps the HTML elements with a React Native equivalent to the
+// component from react-native that renders them.
+var nativeElements = map[atom.Atom]string{
+	atom.Div:        "View",
+	atom.Section:    "View",
+	atom.Article:    "View",
+	atom.Aside:      "View",
+	atom.Header:     "View",
+	atom.Footer:     "View",
+	atom.Main:       "View",
+	atom.Nav:        "View",
+	atom.Form:       "View",
+	atom.Fieldset:   "View",
+	atom.Figure:     "View",
+	atom.Ul:         "View",
+	atom.Ol:         "View",
+	atom.Li:         "View",
+	atom.P:          "Text",
+	atom.Span:       "Text",
+	atom.H1:         "Text",
+	atom.H2:         "Text",
+	atom.H3:         "Text",
+	atom.H4:         "Text",
+	atom.H5:         "Text",
+	atom.H6:         "Text",
+	atom.Label:      "Text",
+	atom.Legend:     "Text",
+	atom.Figcaption: "Text",
+	atom.Blockquote: "Text",
+	atom.Pre:        "Text",
+	atom.Code:       "Text",
+	atom.Strong:     "Text",
+	atom.Em:         "Text",
+	atom.B:          "Text",
+	atom.I:          "Text",
+	atom.U:          "Text",
+	atom.S:          "Text",
+	atom.Small:      "Text",
+	atom.Mark:       "Text",
+	atom.Sub:        "Text",
+	atom.Sup:        "Text",
+	atom.Abbr:       "Text",
+	atom.Cite:       "Text",
+	atom.Q:          "Text",
+	atom.Time:       "Text",
+	atom.Img:        "Image",
+	atom.Input:      "TextInput",
+	atom.Textarea:   "TextInput",
+	atom.Button:     "Pressable",
+}
+
+// nativeKeyboardTypes maps the types of text inputs to the keyboard types of
+// TextInput.
+var nativeKeyboardTypes = map[string]string{
+	"email":  "email-address",
+	"number": "numeric",
+	"tel":    "phone-pad",
+	"url":    "url",
+}
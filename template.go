@@ -0,0 +1,500 @@
+package restache
+
+import (
+	"bufio"
+	"errors"
+	"html"
+	"io"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrTemplateExpr     = errors.New("expression has no html/template equivalent")
+	ErrTemplateChildren = errors.New("component children cannot be passed to an html/template template")
+)
+
+// ToHTMLTemplate writes the component n as the text of a Go html/template
+// template, for rendering the same markup on the server. The default
+// component is written as is, or defined under its name if it has one, and
+// each named component is defined under its name.
+//
+// Variables become fields of the data, which is expected to be a map keyed
+// by prop name, with a trailing length becoming a call of len, and sections
+// map to {{if}} and {{range}}, where the dot is the item. Component tags execute the template of the component's
+// PascalCase name with their attributes as a map built by a dict function,
+// and raw HTML is passed through a safeHTML function; both, along with the
+// filters and helpers templates call, are expected in the template's
+// FuncMap. Event handlers, keys and scripts are left out, and props keep no
+// defaults.
+func ToHTMLTemplate(w io.Writer, n *Node) (int, error) {
+	buf := bufio.NewWriter(w)
+	t := &templateRenderer{&renderer{w: buf}}
+	if err := t.render(n); err != nil {
+		return 0, err
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	return t.written, nil
+}
+
+type templateRenderer struct {
+	*renderer
+}
+
+func (t *templateRenderer) render(n *Node) error {
+	if n.Type != ComponentNode {
+		return t.renderNode(n)
+	}
+	sep := false
+	if body := n.body(); body != nil {
+		t.names = n.scriptNames()
+		if err := t.renderDefinition(n.Data, body); err != nil {
+			return err
+		}
+		sep = true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != ComponentNode {
+			continue
+		}
+		if sep {
+			if err := t.print1('\n'); err != nil {
+				return err
+			}
+		}
+		t.names = c.scriptNames()
+		if err := t.renderDefinition(c.Data, c.body()); err != nil {
+			return err
+		}
+		sep = true
+	}
+	return nil
+}
+
+// renderDefinition prints body, defined as the template name unless name is
+// empty.
+func (t *templateRenderer) renderDefinition(name string, body *Node) error {
+	if name != "" {
+		if err := t.printf("{{define %q}}", name); err != nil {
+			return err
+		}
+	}
+	if body != nil {
+		if err := t.renderNode(body); err != nil {
+			return err
+		}
+	}
+	if name != "" {
+		return t.print("{{end}}")
+	}
+	return nil
+}
+
+func (t *templateRenderer) renderChildren(p *Node) error {
+	for c := p.FirstChild; c != nil; c = c.NextSibling {
+		if err := t.renderNode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *templateRenderer) renderNode(n *Node) error {
+	switch n.Type {
+	case ErrorNode:
+		return ErrErrorNode
+	case TextNode:
+		if n.isRawText() {
+			return t.print(templateText(n.Data))
+		}
+		return t.print(templateText(html.EscapeString(n.Data)))
+	case ElementNode:
+		return t.renderElement(n)
+	case VariableNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		return t.renderAction(e)
+	case WhenNode, UnlessNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		if n.Type == UnlessNode {
+			e = &UnaryExpr{Op: "!", X: e}
+		}
+		cmd, err := t.command(e)
+		if err != nil {
+			return err
+		}
+		if err := t.printf("{{if %s}}", cmd); err != nil {
+			return err
+		}
+		if err := t.renderChildren(n); err != nil {
+			return err
+		}
+		return t.print("{{end}}")
+	case RangeNode:
+		e, err := n.expr()
+		if err != nil {
+			return err
+		}
+		cmd, err := t.command(e)
+		if err != nil {
+			return err
+		}
+		if err := t.printf("{{range %s}}", cmd); err != nil {
+			return err
+		}
+		t.scope++
+		err = t.renderChildren(n)
+		t.scope--
+		if err != nil {
+			return err
+		}
+		return t.print("{{end}}")
+	case TranslateNode:
+		return t.renderTranslate(n)
+	case CommentNode:
+		return nil // html/template strips comments
+	case UnescapedNode:
+		return ErrSoleChildOnly
+	default:
+		return ErrUnknownNode
+	}
+}
+
+func (t *templateRenderer) renderElement(n *Node) error {
+	if n.DataAtom == 0 && (n.Data == "" || n.Data == keyedFragment) {
+		return t.renderChildren(n)
+	}
+	if n.DataAtom == 0 && !n.CustomElement {
+		return t.renderComponent(n)
+	}
+	tagName := n.TagName()
+	if err := t.printf("<%s", tagName); err != nil {
+		return err
+	}
+	for _, a := range n.Attr {
+		if err := t.renderAttribute(n, a); err != nil {
+			return err
+		}
+	}
+	if err := t.print1('>'); err != nil {
+		return err
+	}
+	if _, ok := voidElements[n.DataAtom]; ok {
+		if n.FirstChild != nil {
+			return ErrVoidChildren
+		}
+		return nil
+	}
+	if c := n.FirstChild; c != nil && c.Type == UnescapedNode && c.NextSibling == nil {
+		e, err := c.expr()
+		if err != nil {
+			return err
+		}
+		if err := t.renderAction(&CallExpr{Func: "safeHTML", Args: []Expr{e}}); err != nil {
+			return err
+		}
+	} else if err := t.renderChildren(n); err != nil {
+		return err
+	}
+	return t.printf("</%s>", tagName)
+}
+
+func (t *templateRenderer) renderAttribute(n *Node, a Attribute) error {
+	name := htmlAttrName(n, a)
+	if a.Key == "key" || strings.HasPrefix(name, "on") && a.IsExpr {
+		return nil // meaningless on the server
+	}
+	_, isBool := boolAttrs[a.KeyAtom]
+	isBool = isBool && a.KeyAtom != 0
+	if !a.IsExpr {
+		if a.Val == "" && isBool {
+			return t.printf(" %s", name)
+		}
+		return t.printf(` %s="%s"`, name, templateText(html.EscapeString(a.Val)))
+	}
+	e, err := a.expr()
+	if err != nil {
+		return err
+	}
+	if isBool {
+		cmd, err := t.command(e)
+		if err != nil {
+			return err
+		}
+		return t.printf("{{if %s}} %s{{end}}", cmd, name)
+	}
+	if err := t.printf(` %s="`, name); err != nil {
+		return err
+	}
+	if err := t.renderAction(e); err != nil {
+		return err
+	}
+	return t.print1('"')
+}
+
+// renderComponent executes the template of the component n with its
+// attributes as data.
+func (t *templateRenderer) renderComponent(n *Node) error {
+	if n.FirstChild != nil {
+		return ErrTemplateChildren
+	}
+	data := "."
+	if len(n.Attr) > 0 {
+		args := []string{"dict"}
+		for _, a := range n.Attr {
+			if a.Key == "key" {
+				continue
+			}
+			var val string
+			if a.IsExpr {
+				e, err := a.expr()
+				if err != nil {
+					return err
+				}
+				if val, err = t.operand(e); err != nil {
+					return err
+				}
+			} else {
+				val = goString(jsString(a.Val))
+			}
+			args = append(args, goString(jsString(attrKey(n, a))), val)
+		}
+		data = "(" + strings.Join(args, " ") + ")"
+	}
+	return t.printf("{{template %q %s}}", pascalize(n.Data), data)
+}
+
+// renderTranslate prints the default message of the translation n.
+func (t *templateRenderer) renderTranslate(n *Node) error {
+	m, params, err := n.message()
+	if err != nil {
+		return err
+	}
+	if err := m.checkDefault(); err != nil {
+		return err
+	}
+	if m.Plurals == nil {
+		if m.Text == "" {
+			return t.print(templateText(html.EscapeString(m.Key)))
+		}
+		return t.renderMessage(m.Text, params)
+	}
+	var count Attribute
+	for _, a := range params {
+		if a.Key == "count" {
+			count = a
+		}
+	}
+	var c string
+	if count.IsExpr {
+		e, err := count.expr()
+		if err != nil {
+			return err
+		}
+		if c, err = t.operand(e); err != nil {
+			return err
+		}
+	} else {
+		c = count.Val
+	}
+	branch := "if"
+	for i, cat := range []string{"zero", "one", "two"} {
+		form, ok := m.Plurals[cat]
+		if !ok {
+			continue
+		}
+		if err := t.printf("{{%s eq %s %d}}", branch, c, i); err != nil {
+			return err
+		}
+		if err := t.renderMessage(form, params); err != nil {
+			return err
+		}
+		branch = "else if"
+	}
+	if branch != "if" {
+		if err := t.print("{{else}}"); err != nil {
+			return err
+		}
+	}
+	if err := t.renderMessage(m.Plurals["other"], params); err != nil {
+		return err
+	}
+	if branch != "if" {
+		return t.print("{{end}}")
+	}
+	return nil
+}
+
+// renderMessage prints msg with its placeholders replaced by the values of
+// params.
+func (t *templateRenderer) renderMessage(msg string, params []Attribute) error {
+	for msg != "" {
+		i := strings.IndexByte(msg, '{')
+		j := strings.IndexByte(msg[i+1:], '}') + i + 1
+		if i < 0 || j <= i {
+			break
+		}
+		if err := t.print(templateText(html.EscapeString(msg[:i]))); err != nil {
+			return err
+		}
+		name := msg[i+1 : j]
+		k := slices.IndexFunc(params, func(a Attribute) bool { return a.Key == name })
+		switch {
+		case k < 0:
+			if err := t.print(templateText(html.EscapeString(msg[i : j+1]))); err != nil {
+				return err
+			}
+		case !params[k].IsExpr:
+			if err := t.print(templateText(html.EscapeString(params[k].Val))); err != nil {
+				return err
+			}
+		default:
+			e, err := params[k].expr()
+			if err != nil {
+				return err
+			}
+			if err := t.renderAction(e); err != nil {
+				return err
+			}
+		}
+		msg = msg[j+1:]
+	}
+	return t.print(templateText(html.EscapeString(msg)))
+}
+
+// renderAction prints an action evaluating e. A conditional at the top
+// becomes an {{if}} action, as templates have no such operator.
+func (t *templateRenderer) renderAction(e Expr) error {
+	if c, ok := e.(*CondExpr); ok {
+		cond, err := t.command(c.Cond)
+		if err != nil {
+			return err
+		}
+		if err := t.printf("{{if %s}}", cond); err != nil {
+			return err
+		}
+		if err := t.renderAction(c.Then); err != nil {
+			return err
+		}
+		if err := t.print("{{else}}"); err != nil {
+			return err
+		}
+		if err := t.renderAction(c.Else); err != nil {
+			return err
+		}
+		return t.print("{{end}}")
+	}
+	cmd, err := t.command(e)
+	if err != nil {
+		return err
+	}
+	return t.printf("{{%s}}", cmd)
+}
+
+// command returns the template command evaluating e.
+func (t *templateRenderer) command(e Expr) (string, error) {
+	var fn string
+	var args []Expr
+	switch e := e.(type) {
+	case *PathExpr:
+		if t.scope == 0 && slices.Contains(t.names, e.Parts[0]) {
+			return "", ErrTemplateExpr // defined by the script
+		}
+		if n := len(e.Parts); n > 1 && e.Parts[n-1] == "length" {
+			return "len ." + strings.Join(e.Parts[:n-1], "."), nil
+		}
+		return "." + strings.Join(e.Parts, "."), nil
+	case *LiteralExpr:
+		switch e.Kind {
+		case StringLiteral:
+			return goString(e.Value), nil
+		case NumberLiteral, BoolLiteral:
+			return e.Value, nil
+		case NullLiteral, UndefinedLiteral:
+			return "nil", nil
+		}
+		return "", ErrTemplateExpr
+	case *UnaryExpr:
+		fn, args = "not", []Expr{e.X}
+	case *BinaryExpr:
+		fn, args = templateFuncs[e.Op], []Expr{e.X, e.Y}
+	case *CallExpr:
+		fn, args = e.Func, e.Args
+	default:
+		return "", ErrTemplateExpr
+	}
+	cmd := []string{fn}
+	for _, arg := range args {
+		s, err := t.operand(arg)
+		if err != nil {
+			return "", err
+		}
+		cmd = append(cmd, s)
+	}
+	return strings.Join(cmd, " "), nil
+}
+
+// operand returns e as an argument of a command.
+func (t *templateRenderer) operand(e Expr) (string, error) {
+	s, err := t.command(e)
+	if err != nil {
+		return "", err
+	}
+	switch e.(type) {
+	case *PathExpr, *LiteralExpr:
+		if !strings.HasPrefix(s, "len ") {
+			return s, nil
+		}
+	}
+	return "(" + s + ")", nil
+}
+
+// templateFuncs maps the binary operators to the builtin template functions
+// closest to them.
+var templateFuncs = map[string]string{
+	"&&":  "and",
+	"||":  "or",
+	"??":  "or",
+	"==":  "eq",
+	"===": "eq",
+	"!=":  "ne",
+	"!==": "ne",
+	"<":   "lt",
+	"<=":  "le",
+	">":   "gt",
+	">=":  "ge",
+}
+
+// templateText escapes the action delimiters in the text s.
+func templateText(s string) string {
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
+
+// goString converts the JavaScript string literal js to a Go one.
+func goString(js string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 1; i < len(js)-1; i++ {
+		switch c := js[i]; {
+		case c == '\\' && js[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == '\\':
+			b.WriteString(js[i : i+2])
+			i++
+		case c == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
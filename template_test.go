@@ -0,0 +1,118 @@
+package restache_test
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/tetsuo/restache"
+)
+
+func TestToHTMLTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{
+			data:     `<p class="note" data-id={id}>Hi {name} &amp; co</p>`,
+			expected: `<p class="note" data-id="{{.id}}">Hi {{.name}} &amp; co</p>`,
+		},
+		{
+			data:     `<button onClick={save} disabled="{busy || !ready}">Save</button>`,
+			expected: `<button{{if or .busy (not .ready)}} disabled{{end}}>Save</button>`,
+		},
+		{
+			data:     `<ul>{#items}<li key={id}>{name | upper}</li>{/items}</ul>`,
+			expected: `<ul>{{range .items}}<li>{{upper .name}}</li>{{end}}</ul>`,
+		},
+		{
+			data:     `{?user.admin}<b>admin</b>{/user.admin}{^user}<i>guest</i>{/user}`,
+			expected: `{{if .user.admin}}<b>admin</b>{{end}}{{if not .user}}<i>guest</i>{{end}}`,
+		},
+		{
+			data:     `<span title="{count > 1 ? 'many' : 'one'}">{n === 0 ? "none" : n}</span>`,
+			expected: `<span title="{{if gt .count 1}}{{"many"}}{{else}}{{"one"}}{{end}}">{{if eq .n 0}}{{"none"}}{{else}}{{.n}}{{end}}</span>`,
+		},
+		{
+			data:     `<script>const name = "x";</script><ul>{#items}<li>{name}</li>{/items}</ul>`,
+			expected: `<ul>{{range .items}}<li>{{.name}}</li>{{end}}</ul>`,
+		},
+		{
+			data:     `<div>{&body}</div><input type="checkbox" checked>`,
+			expected: `<div>{{safeHTML .body}}</div><input type="checkbox" checked>`,
+		},
+		{
+			data: `<table>{#rows}<user-row name={name} admin />{/rows}</table>
+<template name="user-row"><tr><td>{name}</td></tr></template>`,
+			expected: `<table>{{range .rows}}{{template "UserRow" (dict "name" .name "admin" "")}}{{end}}</table>` + "\n" +
+				`{{define "UserRow"}}<tr><td>{{.name}}</td></tr>{{end}}`,
+		},
+		{
+			data:     `<p><t key="greet" name="{user.name}">Hello, {name}!</t> {t "cart" count=items.length one="{count} item" other="{count} items"}</p>`,
+			expected: `<p>Hello, {{.user.name}}! {{if eq (len .items) 1}}{{len .items}} item{{else}}{{len .items}} items{{end}}</p>`,
+		},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.ToHTMLTemplate(&sb, parseNode(t, tc.data)); err != nil {
+				t.Fatalf("ToHTMLTemplate error: %v", err)
+			}
+			if got := sb.String(); got != tc.expected {
+				t.Errorf("ToHTMLTemplate mismatch:\nwant:\n%s\ngot:\n%s\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestToHTMLTemplateExecute(t *testing.T) {
+	root := parseNode(t, `<ul data-count={items.length}>{#items}<todo-item text={text} done={done} />{/items}</ul>
+<p>{t "left" count=items.length one="{count} item" other="{count} items"}</p>
+<template name="todo-item"><li class="{done ? 'done' : ''}">{text}</li></template>`)
+	root.Data = "TodoList"
+	var sb strings.Builder
+	if _, err := restache.ToHTMLTemplate(&sb, root); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"dict": func(kv ...any) map[string]any {
+			m := make(map[string]any)
+			for i := 0; i+1 < len(kv); i += 2 {
+				m[kv[i].(string)] = kv[i+1]
+			}
+			return m
+		},
+	}).Parse(sb.String())
+	if err != nil {
+		t.Fatalf("parse %s: %v", sb.String(), err)
+	}
+	var out strings.Builder
+	err = tmpl.ExecuteTemplate(&out, "TodoList", map[string]any{
+		"items": []map[string]any{{"text": "a<b", "done": true}, {"text": "c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `<ul data-count="2"><li class="done">a&lt;b</li><li class="">c</li></ul><p>2 items</p>`
+	if got := out.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestToHTMLTemplateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		err  error
+	}{
+		{`<my-card>hi</my-card>`, restache.ErrTemplateChildren},
+		{`<p>{list | join []}</p>`, restache.ErrTemplateExpr},
+		{"<script>const greet = () => 'hi';</script><p>{greet}</p>", restache.ErrTemplateExpr},
+		{`<p>{t "n" count=n many="{count} items" other="{count} items"}</p>`, restache.ErrPluralForm},
+	} {
+		t.Run(tc.data, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := restache.ToHTMLTemplate(&sb, parseNode(t, tc.data)); err != tc.err {
+				t.Errorf("got error %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
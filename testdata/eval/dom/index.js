@@ -0,0 +1,128 @@
+// A fake document for running components rendered with TargetDOM in node.
+// Elements print as HTML, with the properties set on them as .name=value.
+
+const escape = (s) => String(s).replace(/[&<>"]/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;" })[c]);
+
+const voidElements = new Set(["area", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr"]);
+
+class Node {
+  constructor() {
+    this.parentNode = null;
+    this.childNodes = [];
+  }
+  get nextSibling() {
+    const p = this.parentNode;
+    return p ? p.childNodes[p.childNodes.indexOf(this) + 1] ?? null : null;
+  }
+  get firstChild() {
+    return this.childNodes[0] ?? null;
+  }
+  insert(nodes, at) {
+    const list = [];
+    for (let n of nodes) {
+      if (typeof n === "string") {
+        n = new Text(n);
+      }
+      if (n instanceof DocumentFragment) {
+        list.push(...n.childNodes);
+        n.childNodes = [];
+      } else {
+        n.remove();
+        list.push(n);
+      }
+    }
+    list.forEach((n) => (n.parentNode = this));
+    this.childNodes.splice(at, 0, ...list);
+  }
+  append(...nodes) {
+    this.insert(nodes, this.childNodes.length);
+  }
+  prepend(...nodes) {
+    this.insert(nodes, 0);
+  }
+  before(...nodes) {
+    this.parentNode.insert(nodes, this.parentNode.childNodes.indexOf(this));
+  }
+  remove() {
+    const p = this.parentNode;
+    if (p) {
+      p.childNodes.splice(p.childNodes.indexOf(this), 1);
+      this.parentNode = null;
+    }
+  }
+}
+
+class Text extends Node {
+  constructor(data) {
+    super();
+    this.data = data;
+  }
+  toString() {
+    return escape(this.data);
+  }
+}
+
+class Comment extends Node {
+  constructor(data) {
+    super();
+    this.data = data;
+  }
+  toString() {
+    return "";
+  }
+}
+
+class DocumentFragment extends Node {
+  toString() {
+    return this.childNodes.join("");
+  }
+}
+
+class Element extends Node {
+  constructor(tag) {
+    super();
+    this.tag = tag;
+    this.attrs = new Map();
+    this.listeners = {};
+  }
+  setAttribute(name, value) {
+    this.attrs.set(name, String(value));
+  }
+  removeAttribute(name) {
+    this.attrs.delete(name);
+  }
+  addEventListener(type, fn) {
+    this.listeners[type] = fn;
+  }
+  dispatch(type) {
+    this.listeners[type]?.({ type });
+  }
+  set innerHTML(html) {
+    this.childNodes = [];
+    this.html = html;
+  }
+  toString() {
+    let attrs = "";
+    for (const [k, v] of this.attrs) {
+      attrs += ` ${k}="${escape(v)}"`;
+    }
+    for (const k of ["value", "checked"]) {
+      if (this[k] !== undefined) {
+        attrs += ` .${k}=${JSON.stringify(this[k])}`;
+      }
+    }
+    if (voidElements.has(this.tag)) {
+      return `<${this.tag}${attrs}>`;
+    }
+    return `<${this.tag}${attrs}>${this.html ?? this.childNodes.join("")}</${this.tag}>`;
+  }
+}
+
+globalThis.Node = Node;
+globalThis.document = {
+  createDocumentFragment: () => new DocumentFragment(),
+  createElement: (tag) => new Element(tag),
+  createElementNS: (ns, tag) => new Element(tag),
+  createComment: (data) => new Comment(data),
+  createTextNode: (data) => new Text(data),
+};
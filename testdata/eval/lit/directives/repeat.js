@@ -0,0 +1 @@
+export const repeat = (items, key, template) => Array.from(items, (item, i) => template(item, i));
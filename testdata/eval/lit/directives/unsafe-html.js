@@ -0,0 +1 @@
+export const unsafeHTML = (s) => ({ unsafeHTML: String(s ?? "") });
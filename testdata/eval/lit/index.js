@@ -0,0 +1,51 @@
+// A stand-in for lit that renders templates to HTML strings, so tests can
+// run compiled templates with node.
+
+export const nothing = Symbol("nothing");
+
+export const html = (strings, ...values) => ({ strings, values });
+
+export class LitElement {}
+
+export function escape(s) {
+  return String(s).replace(/[&<>"]/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;" })[c]);
+}
+
+function child(v) {
+  if (v == null || v === false || v === nothing) {
+    return "";
+  }
+  if (Array.isArray(v)) {
+    return v.map(child).join("");
+  }
+  if (typeof v === "object") {
+    return "unsafeHTML" in v ? v.unsafeHTML : render(v);
+  }
+  return escape(v);
+}
+
+// render returns the HTML of a template result. Property and boolean
+// attribute bindings render as attributes, and event listeners are left out.
+export function render({ strings, values }) {
+  let out = strings[0];
+  values.forEach((v, i) => {
+    const binding = /\s([.?@])([\w-]+)=$/.exec(out);
+    if (binding) {
+      out = out.slice(0, binding.index);
+      const [, kind, name] = binding;
+      if (kind === "?" || kind === "." && typeof v === "boolean") {
+        out += v ? " " + name : "";
+      } else if (kind === "." && v != null) {
+        out += ` ${name}="${escape(v)}"`;
+      }
+    } else if (/=$/.test(out)) {
+      out += `"${v === nothing || v == null ? "" : escape(v)}"`;
+    } else if (/="[^"]*$/.test(out)) {
+      out += v === nothing || v == null ? "" : escape(v);
+    } else {
+      out += child(v);
+    }
+    out += strings[i + 1];
+  });
+  return out;
+}
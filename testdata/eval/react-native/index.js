@@ -0,0 +1,7 @@
+// Components of React Native render as elements named after them.
+
+export const View = "View";
+export const Text = "Text";
+export const Pressable = "Pressable";
+export const TextInput = "TextInput";
+export const Image = "Image";
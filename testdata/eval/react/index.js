@@ -0,0 +1,70 @@
+// A stand-in for React that renders elements to HTML strings, so tests can
+// run compiled templates with node.
+
+export const Fragment = Symbol("Fragment");
+
+export function createElement(type, props, ...children) {
+  props = { ...props };
+  if (children.length > 0) {
+    props.children = children.length === 1 ? children[0] : children;
+  }
+  return { type, props };
+}
+
+export const memo = (component) => component;
+
+export const forwardRef = (render) => (props) => render(props, props.ref ?? null);
+
+const voidElements = new Set(["area", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr"]);
+
+const attrNames = { className: "class", htmlFor: "for" };
+
+export function escape(s) {
+  return String(s).replace(/[&<>"]/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;" })[c]);
+}
+
+function style(v) {
+  if (Array.isArray(v)) {
+    v = Object.assign({}, ...v.flat(Infinity).filter(Boolean));
+  }
+  return Object.entries(v).map(([k, x]) => k.replace(/[A-Z]/g, (c) => "-" + c.toLowerCase()) + ":" + x).join(";");
+}
+
+export function renderToString(node) {
+  if (node == null || typeof node === "boolean") {
+    return "";
+  }
+  if (Array.isArray(node)) {
+    return node.map(renderToString).join("");
+  }
+  if (typeof node !== "object") {
+    return escape(node);
+  }
+  const { type, props } = node;
+  if (type === Fragment) {
+    return renderToString(props.children);
+  }
+  if (typeof type === "function") {
+    return renderToString(type(props));
+  }
+  let attrs = "";
+  for (const [k, v] of Object.entries(props)) {
+    if (k === "children" || k === "key" || k === "ref" || k === "dangerouslySetInnerHTML" ||
+        v == null || v === false || typeof v === "function") {
+      continue;
+    }
+    const name = attrNames[k] ?? k;
+    if (v === true) {
+      attrs += " " + name;
+    } else if (k === "style" && typeof v === "object") {
+      attrs += ` style="${escape(style(v))}"`;
+    } else {
+      attrs += ` ${name}="${escape(v)}"`;
+    }
+  }
+  if (voidElements.has(type)) {
+    return `<${type}${attrs}>`;
+  }
+  const inner = props.dangerouslySetInnerHTML?.__html ?? renderToString(props.children);
+  return `<${type}${attrs}>${inner}</${type}>`;
+}
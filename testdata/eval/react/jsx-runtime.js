@@ -0,0 +1,9 @@
+import { createElement, Fragment } from "./index.js";
+
+export { Fragment };
+
+export function jsx(type, props, key) {
+  return createElement(type, key === undefined ? props : { ...props, key });
+}
+
+export const jsxs = jsx;
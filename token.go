@@ -3,6 +3,7 @@ package restache
 import (
 	"bytes"
 	"io"
+	"strconv"
 
 	"golang.org/x/net/html"
 )
@@ -22,8 +23,33 @@ const (
 	UnlessToken
 	RangeToken
 	EndControlToken
+	UnescapedToken
+	PragmaToken
+	DirectiveToken
 )
 
+// Position is a line and column in the template source, both starting at 1.
+// Columns count bytes.
+type Position struct {
+	Line, Col int
+}
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Col)
+}
+
+func (p Position) advance(b []byte) Position {
+	for _, c := range b {
+		if c == '\n' {
+			p.Line++
+			p.Col = 1
+		} else {
+			p.Col++
+		}
+	}
+	return p
+}
+
 // Tokenizer holds state for parsing.
 type Tokenizer struct {
 	z        *html.Tokenizer
@@ -34,14 +60,36 @@ type Tokenizer struct {
 	bufEnd   int    // end of buf
 	tokBegin int    // start offset of current token in buf
 	tokEnd   int    // end offset of current token in buf
+
+	trimBefore, trimAfter bool // whitespace-control markers of the current tag
+
+	rawText     bool // the last tag opened a script or style element
+	interpolate bool // split the raw text of the current element on tags
+
+	rawEnd Position // position following the last token read from z
+	cur    Position // position of the current token
+	next   Position // position of buf[pos]
 }
 
 func NewTokenizer(r io.Reader) *Tokenizer {
 	return &Tokenizer{
-		z: html.NewTokenizer(r),
+		z:      html.NewTokenizer(r),
+		rawEnd: Position{Line: 1, Col: 1},
 	}
 }
 
+// Pos returns the source position of the current token.
+func (t *Tokenizer) Pos() Position {
+	return t.cur
+}
+
+// seek moves the parse position in buf to end, after the current token.
+func (t *Tokenizer) seek(end int) {
+	t.cur = t.next
+	t.next = t.next.advance(t.buf[t.pos:end])
+	t.pos = end
+}
+
 // Err returns the last error encountered by the tokenizer.
 func (t *Tokenizer) Err() error {
 	return t.err
@@ -57,6 +105,33 @@ func (t *Tokenizer) TagName() ([]byte, bool) {
 	return t.z.TagName()
 }
 
+// Trim reports whether the current tag has whitespace-control markers, as in
+// {~#items} (before) and {/items~} (after).
+func (t *Tokenizer) Trim() (before, after bool) {
+	return t.trimBefore, t.trimAfter
+}
+
+// Pragma returns the name and argument of a <!-- restache:name arg -->
+// comment.
+func (t *Tokenizer) Pragma() (name, arg []byte) {
+	b := bytes.TrimSpace(t.Raw()[len(pragmaPrefix):])
+	if i := bytes.IndexAny(b, whitespace); i >= 0 {
+		return b[:i], bytes.TrimSpace(b[i+1:])
+	}
+	return b, nil
+}
+
+// Directive returns the name and arguments of a directive such as
+// {@props title="Untitled"}.
+func (t *Tokenizer) Directive() (name, args []byte) {
+	b := t.Raw()
+	b = bytes.TrimSpace(b[bytes.IndexByte(b, '@')+1:])
+	if i := bytes.IndexAny(b, whitespace); i >= 0 {
+		return b[:i], bytes.TrimSpace(b[i+1:])
+	}
+	return b, nil
+}
+
 // Comment extracts the content of a comment.
 func (t *Tokenizer) Comment() []byte {
 	// Find the first comment symbol, and return the rest
@@ -124,6 +199,8 @@ func (t *Tokenizer) Next() TokenType {
 		return t.tt
 	}
 
+	t.trimBefore, t.trimAfter = false, false
+
 	// Still have leftover text in buf?
 	if t.pos < t.bufEnd {
 		t.parseTextSegment()
@@ -133,6 +210,13 @@ func (t *Tokenizer) Next() TokenType {
 consume:
 	for {
 		tt := t.z.Next()
+		start := t.rawEnd
+		t.rawEnd = start.advance(t.z.Raw())
+		t.cur, t.next = start, start
+		if tt != html.TextToken {
+			t.rawText = tt == html.StartTagToken && isRawTextTag(t.z.Raw())
+			t.interpolate = false
+		}
 		switch tt {
 		case html.ErrorToken:
 			t.err = t.z.Err()
@@ -142,6 +226,12 @@ consume:
 			t.buf = t.z.Text()
 			t.pos = 0
 			t.bufEnd = len(t.buf)
+			if t.rawText && !t.interpolate {
+				t.tt = TextToken
+				t.tokBegin, t.tokEnd = 0, t.bufEnd
+				t.seek(t.bufEnd)
+				return t.tt
+			}
 			t.parseTextSegment()
 			return t.tt
 		case html.StartTagToken:
@@ -153,6 +243,14 @@ consume:
 		case html.EndTagToken:
 			t.tt = EndTagToken
 			break consume
+		case html.CommentToken:
+			if text := bytes.TrimSpace(t.z.Text()); bytes.HasPrefix(text, pragmaPrefix) {
+				t.buf = text
+				t.pos, t.bufEnd = len(text), len(text)
+				t.tokBegin, t.tokEnd = 0, len(text)
+				t.tt = PragmaToken
+				return t.tt
+			}
 		}
 	}
 	t.buf = t.z.Raw()
@@ -164,6 +262,12 @@ consume:
 	return t.tt
 }
 
+var (
+	tripleOpen   = []byte("{{{")
+	tripleClose  = []byte("}}}")
+	pragmaPrefix = []byte("restache:")
+)
+
 func (t *Tokenizer) parseTextSegment() {
 	b := t.buf
 	start := t.pos
@@ -175,7 +279,7 @@ func (t *Tokenizer) parseTextSegment() {
 		t.tt = TextToken
 		t.tokBegin = start
 		t.tokEnd = t.bufEnd
-		t.pos = t.bufEnd
+		t.seek(t.bufEnd)
 		return
 	}
 	lpos += start // adjust lpos to absolute index in b
@@ -185,10 +289,22 @@ func (t *Tokenizer) parseTextSegment() {
 		t.tt = TextToken
 		t.tokBegin = start
 		t.tokEnd = lpos
-		t.pos = lpos // Next time we call parseTextSegment, we handle the '{'
+		t.seek(lpos) // Next time we call parseTextSegment, we handle the '{'
 		return
 	}
 
+	// Triple braces hold an unescaped variable: {{{html}}}
+	if bytes.HasPrefix(b[lpos:], tripleOpen) {
+		if rpos := bytes.Index(b[lpos+3:], tripleClose); rpos >= 0 {
+			rpos += lpos + 3
+			t.tt = UnescapedToken
+			t.tokBegin = lpos + 3
+			t.tokEnd = rpos
+			t.seek(rpos + 3)
+			return
+		}
+	}
+
 	// If we get here, it means b[start] == '{', find the matching '}'
 	rpos := bytes.IndexByte(b[lpos+1:], '}')
 	if rpos < 0 {
@@ -196,17 +312,80 @@ func (t *Tokenizer) parseTextSegment() {
 		t.tt = TextToken
 		t.tokBegin = lpos
 		t.tokEnd = t.bufEnd
-		t.pos = t.bufEnd
+		t.seek(t.bufEnd)
 		return
 	}
 	rpos += (lpos + 1)
 
 	t.tt = identifyKeyword(b[lpos+1 : rpos])
+	if t.tt != CommentToken {
+		// Expressions may hold string literals containing '}'
+		if q := closingBrace(b[lpos+1:]); q >= 0 {
+			rpos = lpos + 1 + q
+		}
+	}
 
 	t.tokBegin = lpos + 1
 	t.tokEnd = rpos
+	t.trimMarkers()
+
+	if t.tt == UnescapedToken {
+		t.tokBegin += bytes.IndexByte(b[t.tokBegin:t.tokEnd], '&') + 1
+	}
 
-	t.pos = rpos + 1
+	t.seek(rpos + 1)
+}
+
+// isRawTextTag reports whether raw, a start tag, opens an element whose
+// content is raw text: a script or a style sheet.
+func isRawTextTag(raw []byte) bool {
+	name := raw[1:]
+	if i := bytes.IndexAny(name, " \t\n\f\r/>"); i >= 0 {
+		name = name[:i]
+	}
+	return bytes.EqualFold(name, []byte("script")) || bytes.EqualFold(name, []byte("style"))
+}
+
+// trimMarkers strips the whitespace-control markers from the ends of the
+// current tag, recording them in trimBefore and trimAfter.
+func (t *Tokenizer) trimMarkers() {
+	b := t.buf[t.tokBegin:t.tokEnd]
+	if i := bytes.IndexFunc(b, notSpace); i >= 0 && b[i] == '~' {
+		t.tokBegin += i + 1
+		t.trimBefore = true
+		b = b[i+1:]
+	}
+	if i := bytes.LastIndexFunc(b, notSpace); i >= 0 && b[i] == '~' {
+		t.tokEnd = t.tokBegin + i
+		t.trimAfter = true
+	}
+}
+
+func notSpace(r rune) bool {
+	return r >= 256 || !spaceTable[r]
+}
+
+// closingBrace returns the index of the first '}' in b that is not inside a
+// quoted string or an empty object literal, or -1 if there is none.
+func closingBrace(b []byte) int {
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{' && i+1 < len(b) && b[i+1] == '}':
+			i++
+		case c == '}':
+			return i
+		}
+	}
+	return -1
 }
 
 // identifyKeyword looks at the content inside {...} and decides the token type.
@@ -217,6 +396,10 @@ func identifyKeyword(chunk []byte) TokenType {
 	for i < length && spaceTable[chunk[i]] {
 		i++
 	}
+	// Skip a whitespace-control marker
+	if i < length && chunk[i] == '~' {
+		i++
+	}
 	if i >= length {
 		return VariableToken
 	}
@@ -231,6 +414,10 @@ func identifyKeyword(chunk []byte) TokenType {
 		return EndControlToken
 	case '!':
 		return CommentToken
+	case '&':
+		return UnescapedToken
+	case '@':
+		return DirectiveToken
 	default:
 		return VariableToken
 	}
@@ -91,9 +91,18 @@ func TestTokenizer(t *testing.T) {
 				case restache.VariableToken:
 					varName := z.Raw()
 					op += "expr(" + string(varName) + ")"
+				case restache.UnescapedToken:
+					varName := z.Raw()
+					op += "raw(" + string(varName) + ")"
 				case restache.CommentToken:
 					comment := z.Comment()
 					op += "comment(" + string(comment) + ")"
+				case restache.DirectiveToken:
+					name, args := z.Directive()
+					op += "directive(" + string(name) + ", " + string(args) + ")"
+				case restache.PragmaToken:
+					name, arg := z.Pragma()
+					op += "pragma(" + string(name) + ", " + string(arg) + ")"
 				case restache.TextToken:
 					text := z.Raw()
 					if len(bytes.TrimSpace(text)) == 0 {
@@ -101,6 +110,14 @@ func TestTokenizer(t *testing.T) {
 					}
 					op += "text(" + string(text) + ")"
 				}
+				if before, after := z.Trim(); before || after {
+					if before {
+						op = "~" + op
+					}
+					if after {
+						op += "~"
+					}
+				}
 				ops = append(ops, op)
 			}
 